@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is how the table is laid: who sits at it, and how they eat. It can be read from a JSON file, and
+// overridden from the command line.
+type Config struct {
+	Seats        int                 `json:"seats"` // zero means one for each philosopher named, or five if nobody is
+	Hunger       int                 `json:"hunger"`
+	EatTime      Duration            `json:"eat_time"`
+	ThinkTime    Duration            `json:"think_time"`
+	SleepTime    Duration            `json:"sleep_time"`
+	Philosophers []PhilosopherConfig `json:"philosophers"` // seated first, in order; the rest of the seats get made-up names
+}
+
+// PhilosopherConfig is one philosopher at the table. Whatever is left at zero is the same as for everyone else.
+type PhilosopherConfig struct {
+	Name      string   `json:"name"`
+	Hunger    int      `json:"hunger"`
+	EatTime   Duration `json:"eat_time"`
+	ThinkTime Duration `json:"think_time"`
+}
+
+// Duration is a time.Duration that is written in JSON the way Go writes durations, such as "1.5s", or as a
+// number of seconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+		return nil
+	}
+
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return fmt.Errorf("a duration must be a string such as \"1.5s\", or a number of seconds, not %s", data)
+	}
+	*d = Duration(seconds * float64(time.Second))
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// classicNames are who sits at the table when nobody has been named.
+var classicNames = []string{"Plato", "Socrates", "Aristotle", "Pascal", "Locke"}
+
+// defaultConfig is the table described by the package's variables.
+func defaultConfig() Config {
+	return Config{
+		Hunger:    hunger,
+		EatTime:   Duration(eatTime),
+		ThinkTime: Duration(thinkTime),
+		SleepTime: Duration(sleepTime),
+	}
+}
+
+// loadConfig starts from defaults, reads the file named by the -config flag in args if there is one, and then
+// applies the rest of the flags in args on top. The result is validated before it is returned.
+func loadConfig(args []string, defaults Config) (Config, error) {
+	// find the file first, so that the other flags can override what it says
+	var scratch Config
+	var path string
+	if err := configFlags(&scratch, &path).Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg := defaults
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := configFlags(&cfg, &path).Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, cfg.validate()
+}
+
+// configFlags returns the command-line flags, which set the fields of cfg that they are named after.
+func configFlags(cfg *Config, path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("dining-philosophers", flag.ContinueOnError)
+	fs.StringVar(path, "config", "", "a JSON `file` describing the table")
+	fs.IntVar(&cfg.Seats, "seats", cfg.Seats, "how many philosophers sit at the table (default one for each named, or five)")
+	fs.IntVar(&cfg.Hunger, "hunger", cfg.Hunger, "how many times each philosopher eats")
+	fs.DurationVar((*time.Duration)(&cfg.EatTime), "eat", time.Duration(cfg.EatTime), "how long a philosopher takes to eat")
+	fs.DurationVar((*time.Duration)(&cfg.ThinkTime), "think", time.Duration(cfg.ThinkTime), "how long a philosopher thinks after eating")
+	fs.DurationVar((*time.Duration)(&cfg.SleepTime), "sleep", time.Duration(cfg.SleepTime), "how long to pause before and after the meal")
+	fs.Func("names", "comma-separated `names` of the philosophers, in the order they sit", func(names string) error {
+		cfg.Philosophers = nil
+		for _, name := range strings.Split(names, ",") {
+			cfg.Philosophers = append(cfg.Philosophers, PhilosopherConfig{Name: strings.TrimSpace(name)})
+		}
+		return nil
+	})
+	fs.Func("philosopher", "one philosopher's own appetite, such as `Plato,hunger=5,eat=2s,think=1s`; may be repeated", func(s string) error {
+		return cfg.setPhilosopher(s)
+	})
+	return fs
+}
+
+// setPhilosopher sets one philosopher's own hunger and times, from a name followed by comma-separated settings.
+// A philosopher who is not at the table yet takes the next seat.
+func (cfg *Config) setPhilosopher(s string) error {
+	fields := strings.Split(s, ",")
+	name := strings.TrimSpace(fields[0])
+	if name == "" {
+		return errors.New("a philosopher must have a name")
+	}
+
+	p := PhilosopherConfig{Name: name}
+	index := len(cfg.Philosophers)
+	for ii, other := range cfg.Philosophers {
+		if other.Name == name {
+			p, index = other, ii
+		}
+	}
+
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return fmt.Errorf("%s: %q is not a setting such as hunger=5", name, field)
+		}
+		var err error
+		switch key {
+		case "hunger":
+			p.Hunger, err = strconv.Atoi(value)
+		case "eat":
+			var d time.Duration
+			d, err = time.ParseDuration(value)
+			p.EatTime = Duration(d)
+		case "think":
+			var d time.Duration
+			d, err = time.ParseDuration(value)
+			p.ThinkTime = Duration(d)
+		default:
+			return fmt.Errorf("%s: there is no such setting as %q; there are hunger, eat and think", name, key)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if index == len(cfg.Philosophers) {
+		cfg.Philosophers = append(cfg.Philosophers, p)
+	} else {
+		cfg.Philosophers[index] = p
+	}
+	return nil
+}
+
+// readFile reads a JSON file over cfg, so that whatever the file leaves out keeps its value. Fields the file gets
+// wrong, or that do not exist, are errors.
+func (cfg *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// validate checks that the configuration describes a table that can actually be laid, and lists everything that
+// is wrong with it if not.
+func (cfg Config) validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	// with just one seat there is just one fork, and nobody can ever eat
+	check(cfg.Seats == 0 || cfg.Seats >= 2, "seats must be at least 2, not %d", cfg.Seats)
+	check(cfg.Seats == 0 || cfg.Seats >= len(cfg.Philosophers), "there are %d philosophers, but only %d seats",
+		len(cfg.Philosophers), cfg.Seats)
+	check(cfg.Seats != 0 || len(cfg.Philosophers) != 1, "one philosopher cannot dine alone; there must be at least 2 seats")
+	check(cfg.Hunger >= 1, "hunger must be at least 1, not %d", cfg.Hunger)
+	check(cfg.EatTime >= 0, "eat_time cannot be negative")
+	check(cfg.ThinkTime >= 0, "think_time cannot be negative")
+	check(cfg.SleepTime >= 0, "sleep_time cannot be negative")
+
+	named := make(map[string]bool)
+	for _, p := range cfg.Philosophers {
+		check(p.Name != "", "philosophers: every philosopher must have a name")
+		check(!named[p.Name], "philosophers: there are two philosophers called %s", p.Name)
+		named[p.Name] = true
+		check(p.Hunger >= 0, "philosophers: %s cannot be less than not hungry", p.Name)
+		check(p.EatTime >= 0, "philosophers: %s cannot take less than no time to eat", p.Name)
+		check(p.ThinkTime >= 0, "philosophers: %s cannot take less than no time to think", p.Name)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// table seats everyone at the table: first the philosophers who were named, and then, in the seats left over, the
+// classic philosophers and, once they have run out, philosophers known by their seat.
+func (cfg Config) table() []Philosopher {
+	seats := cfg.Seats
+	if seats == 0 {
+		seats = len(cfg.Philosophers)
+	}
+	if seats == 0 {
+		seats = len(classicNames)
+	}
+
+	people := append([]PhilosopherConfig(nil), cfg.Philosophers...)
+	taken := make(map[string]bool)
+	for _, p := range people {
+		taken[p.Name] = true
+	}
+	for _, name := range classicNames {
+		if len(people) < seats && !taken[name] {
+			people = append(people, PhilosopherConfig{Name: name})
+		}
+	}
+	for seat := len(people); len(people) < seats; seat++ {
+		name := fmt.Sprintf("Philosopher #%d", seat+1)
+		if !taken[name] {
+			people = append(people, PhilosopherConfig{Name: name})
+		}
+	}
+
+	return newTable(people)
+}
+
+// newTable lays a round table for people, in the order they sit. There is a fork between every two neighbours: each
+// philosopher's left fork is the right fork of the philosopher before them, and the first philosopher's left fork is
+// the last philosopher's right.
+func newTable(people []PhilosopherConfig) []Philosopher {
+	table := make([]Philosopher, len(people))
+	for ii, p := range people {
+		table[ii] = Philosopher{
+			name:      p.Name,
+			leftFork:  (ii + len(people) - 1) % len(people),
+			rightFork: ii,
+			hunger:    p.Hunger,
+			eatTime:   time.Duration(p.EatTime),
+			thinkTime: time.Duration(p.ThinkTime),
+		}
+	}
+	return table
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_table(t *testing.T) {
+	for _, seats := range []int{2, 5, 50, 1000} {
+		table := Config{Seats: seats}.table()
+		if len(table) != seats {
+			t.Fatalf("%d seats: expected %d philosophers but got %d", seats, seats, len(table))
+		}
+
+		// every fork lies between two neighbours, and is used by nobody else
+		users := make(map[int]int)
+		names := make(map[string]bool)
+		for ii, p := range table {
+			users[p.leftFork]++
+			users[p.rightFork]++
+			if next := table[(ii+1)%seats]; next.leftFork != p.rightFork {
+				t.Errorf("%d seats: %s's right fork is %d, but %s's left fork is %d", seats, p.name, p.rightFork, next.name, next.leftFork)
+			}
+			if names[p.name] {
+				t.Errorf("%d seats: there are two philosophers called %s", seats, p.name)
+			}
+			names[p.name] = true
+		}
+		for fork := 0; fork < seats; fork++ {
+			if users[fork] != 2 {
+				t.Errorf("%d seats: fork %d is used by %d philosophers", seats, fork, users[fork])
+			}
+		}
+	}
+
+	// the default table is the classic one
+	expected := []Philosopher{
+		{name: "Plato", leftFork: 4, rightFork: 0},
+		{name: "Socrates", leftFork: 0, rightFork: 1},
+		{name: "Aristotle", leftFork: 1, rightFork: 2},
+		{name: "Pascal", leftFork: 2, rightFork: 3},
+		{name: "Locke", leftFork: 3, rightFork: 4},
+	}
+	table := Config{}.table()
+	for ii := range expected {
+		if table[ii] != expected[ii] {
+			t.Errorf("expected seat %d to be %+v but got %+v", ii, expected[ii], table[ii])
+		}
+	}
+}
+
+func Test_loadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.json")
+	file := `{"seats": 4, "hunger": 2, "eat_time": "10ms", "philosophers": [{"name": "Kant", "hunger": 5, "think_time": 0.5}]}`
+	if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig([]string{"-config", path, "-think", "1ms", "-philosopher", "Hume,eat=20ms", "-philosopher", "Kant,hunger=6"}, defaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Hunger != 2 || cfg.EatTime != Duration(10*time.Millisecond) || cfg.ThinkTime != Duration(time.Millisecond) {
+		t.Errorf("expected the file and flags to set the table, but got %+v", cfg)
+	}
+
+	table := cfg.table()
+	expected := []Philosopher{
+		{name: "Kant", leftFork: 3, rightFork: 0, hunger: 6, thinkTime: 500 * time.Millisecond},
+		{name: "Hume", leftFork: 0, rightFork: 1, eatTime: 20 * time.Millisecond},
+		{name: "Plato", leftFork: 1, rightFork: 2},
+		{name: "Socrates", leftFork: 2, rightFork: 3},
+	}
+	if len(table) != len(expected) {
+		t.Fatalf("expected %d philosophers but got %d", len(expected), len(table))
+	}
+	for ii := range expected {
+		if table[ii] != expected[ii] {
+			t.Errorf("expected seat %d to be %+v but got %+v", ii, expected[ii], table[ii])
+		}
+	}
+
+	// a table of named philosophers has a seat for each of them
+	cfg, err = loadConfig([]string{"-names", "Hobbes, Hegel, Hume"}, defaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table := cfg.table(); len(table) != 3 || table[1].name != "Hegel" {
+		t.Errorf("expected Hobbes, Hegel and Hume at the table, but got %+v", table)
+	}
+
+	var theTests = []struct {
+		name string
+		args []string
+	}{
+		{"one seat", []string{"-seats", "1"}},
+		{"one philosopher", []string{"-names", "Diogenes"}},
+		{"too few seats", []string{"-seats", "2", "-names", "Hobbes,Hegel,Hume"}},
+		{"not hungry", []string{"-hunger", "0"}},
+		{"negative time", []string{"-eat", "-1s"}},
+		{"same name twice", []string{"-names", "Hume,Hume"}},
+		{"unknown setting", []string{"-philosopher", "Hume,appetite=3"}},
+	}
+	for _, e := range theTests {
+		if _, err := loadConfig(e.args, defaultConfig()); err == nil {
+			t.Errorf("%s: expected an error", e.name)
+		}
+	}
+}
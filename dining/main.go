@@ -1,7 +1,10 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -17,23 +20,22 @@ import (
 // may be eating simultaneously, since there are five philosophers and five forks.
 //
 // This is a simple implementation of Dijkstra's solution to the "Dining
-// Philosophers" dilemma.
+// Philosophers" dilemma. The table can be laid for any number of
+// philosophers, from two up; see the -help flag.
 
 // Philosopher is a struct which stores information about a philosopher.
 type Philosopher struct {
 	name      string
 	rightFork int
 	leftFork  int
+	hunger    int           // zero means as hungry as everyone else
+	eatTime   time.Duration // zero means as long as everyone else
+	thinkTime time.Duration // zero means as long as everyone else
 }
 
-// philosophers is list of all philosophers.
-var philosophers = []Philosopher{
-	{name: "Plato", leftFork: 4, rightFork: 0},
-	{name: "Socrates", leftFork: 0, rightFork: 1},
-	{name: "Aristotle", leftFork: 1, rightFork: 2},
-	{name: "Pascal", leftFork: 2, rightFork: 3},
-	{name: "Locke", leftFork: 3, rightFork: 4},
-}
+// philosophers is list of all philosophers. Plato sits between forks 4 and 0,
+// Socrates between 0 and 1, and so on around the table.
+var philosophers = defaultConfig().table()
 
 // define some variables
 var hunger = 3 // how may time per day does a person eat
@@ -44,6 +46,20 @@ var orderMutex sync.Mutex  // a mutex for the slice orderFinished
 var orderFinished []string // the order in which philosopers finish dining and leave the table
 
 func main() {
+	cfg, err := loadConfig(os.Args[1:], defaultConfig())
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	hunger = cfg.Hunger
+	eatTime = time.Duration(cfg.EatTime)
+	thinkTime = time.Duration(cfg.ThinkTime)
+	sleepTime = time.Duration(cfg.SleepTime)
+	philosophers = cfg.table()
+
 	// print out a welcome message
 	fmt.Println("---------------------------")
 	fmt.Println("Dining Philosophers Problem")
@@ -71,16 +87,16 @@ func dine() {
 
 	// wg is the WaitGroup that keeps track of how many philosophers are still at the table.
 	// When it reaches zero, everyone is finished eating and has left.
-	// We add 5 (the number of philosophers) to this wait group.
+	// We add the number of philosophers to this wait group.
 	wg := &sync.WaitGroup{}
 	wg.Add(len(philosophers))
 
 	// We want everyone to be seated before they start eating,
-	// so create a WaitGroup for that and set it to 5.
+	// so create a WaitGroup for that and set it to the number of philosophers.
 	seated := &sync.WaitGroup{}
 	seated.Add(len(philosophers))
 
-	// forks is a map of all 5 forks.
+	// forks is a map of all the forks, one for each philosopher.
 	// Forks are assigned using the fields leftFork and rightFork in the Philosopher type.
 	// Each fork, then, can be found using the index (an integer), and each fork has a unique mutex.
 	var forks = make(map[int]*sync.Mutex)
@@ -117,8 +133,20 @@ func diningProblem(philosopher Philosopher, wg *sync.WaitGroup, forks map[int]*s
 	// Wait until everyone is seated.
 	seated.Wait()
 
-	// Have this philosopher eatTime and thinkTime "hunger" times (3).
-	for ii := hunger; ii > 0; ii-- {
+	// Some philosophers have their own appetites; the rest are like everyone else.
+	meals, eats, thinks := hunger, eatTime, thinkTime
+	if philosopher.hunger > 0 {
+		meals = philosopher.hunger
+	}
+	if philosopher.eatTime > 0 {
+		eats = philosopher.eatTime
+	}
+	if philosopher.thinkTime > 0 {
+		thinks = philosopher.thinkTime
+	}
+
+	// Have this philosopher eat and think "meals" times.
+	for ii := meals; ii > 0; ii-- {
 		fmt.Println()
 		// Lock both forks
 		if philosopher.leftFork > philosopher.rightFork {
@@ -135,11 +163,11 @@ func diningProblem(philosopher Philosopher, wg *sync.WaitGroup, forks map[int]*s
 
 		// By the time we get to this line, the philosopher has a lock (mutex) on both forks.
 		fmt.Printf("\t\t[%d]: %s has both forks (L:%d R:%d) and is eating..\n", ii, philosopher.name, philosopher.leftFork, philosopher.rightFork)
-		time.Sleep(eatTime)
+		time.Sleep(eats)
 
 		// The philosopher starts to think, but does not drop the forks yet.
 		fmt.Printf("\t\t[%d]: %s is thinking (L:%d R:%d).\n", ii, philosopher.name, philosopher.leftFork, philosopher.rightFork)
-		time.Sleep(thinkTime)
+		time.Sleep(thinks)
 
 		// Unlock the mutexes for both forks.
 		forks[philosopher.leftFork].Unlock()
@@ -39,3 +39,22 @@ func Test_dineWithVaryingDelays(t *testing.T) {
 		}
 	}
 }
+
+func Test_dineAtScale(t *testing.T) {
+	savedPhilosophers, savedEat, savedSleep, savedThink := philosophers, eatTime, sleepTime, thinkTime
+	defer func() {
+		philosophers, eatTime, sleepTime, thinkTime = savedPhilosophers, savedEat, savedSleep, savedThink
+	}()
+	eatTime, sleepTime, thinkTime = 0, 0, 0
+
+	for _, seats := range []int{2, 50, 1000} {
+		orderFinished = []string{}
+		philosophers = Config{Seats: seats}.table()
+
+		dine()
+
+		if len(orderFinished) != seats {
+			t.Errorf("%d seats: expected %d philosophers to finish but got %d", seats, seats, len(orderFinished))
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// MMcK is the M/M/c/K queue of queueing theory: clients arrive as a Poisson process, each service takes an
+// exponentially distributed time, there are C servers, and there is room for at most K clients at once, counting
+// those being served. A shop with C identical barbers and ShopCapacity seats is an M/M/c/K queue with
+// K = C + ShopCapacity, as long as its clients arrive that way and nobody gives up waiting.
+type MMcK struct {
+	ArrivalRate float64 // clients per second
+	ServiceRate float64 // clients per second that each server can manage
+	C           int
+	K           int
+}
+
+// QueueStats are the numbers that queueing theory predicts for a queue, and that a simulation measures.
+type QueueStats struct {
+	Blocking    float64       // the fraction of clients who arrive to find no room, and are turned away
+	QueueLength float64       // the average number of clients waiting, not counting those being served
+	Wait        time.Duration // the average time served clients wait before being served
+}
+
+// predict returns what queueing theory says the queue will do in the long run.
+func (q MMcK) predict() QueueStats {
+	// p[n] is the probability of n clients being in the system, up to the common factor p[0]
+	a := q.ArrivalRate / q.ServiceRate
+	p := make([]float64, q.K+1)
+	p[0] = 1
+	total := 1.0
+	for n := 1; n <= q.K; n++ {
+		servers := n
+		if servers > q.C {
+			servers = q.C
+		}
+		p[n] = p[n-1] * a / float64(servers)
+		total += p[n]
+	}
+	for n := range p {
+		p[n] /= total
+	}
+
+	stats := QueueStats{Blocking: p[q.K]}
+	for n := q.C + 1; n <= q.K; n++ {
+		stats.QueueLength += float64(n-q.C) * p[n]
+	}
+
+	// by Little's law, the queue is as long as the rate clients join it times how long they spend in it
+	if admitted := q.ArrivalRate * (1 - stats.Blocking); admitted > 0 {
+		stats.Wait = time.Duration(stats.QueueLength / admitted * float64(time.Second))
+	}
+	return stats
+}
+
+// QueueAnalysis compares what queueing theory predicts for a shop with what the simulated shop actually did.
+type QueueAnalysis struct {
+	Model     MMcK
+	Runs      int
+	TimeOpen  time.Duration
+	Predicted QueueStats
+	Simulated QueueStats
+}
+
+// analyzeQueue simulates runs days at a shop that is an M/M/c/K queue, each one timeOpen long, and compares the
+// averages over all of them with what queueing theory predicts. The shop starts every day empty, while the
+// predictions are for a shop that has been open forever, so the longer the days, the closer the two should be.
+// The days are random, but seed makes them the same every time.
+func analyzeQueue(model MMcK, timeOpen time.Duration, runs int, seed int64) (QueueAnalysis, error) {
+	analysis := QueueAnalysis{Model: model, Runs: runs, TimeOpen: timeOpen, Predicted: model.predict()}
+
+	var arrived, turnedAway, served int
+	var queueLength float64
+	var waited time.Duration
+	for run := 0; run < runs; run++ {
+		day, err := simulateQueue(model, timeOpen, seed+int64(run))
+		if err != nil {
+			return QueueAnalysis{}, err
+		}
+		arrived += day.ClientsServed + day.ClientsTurnedAway
+		turnedAway += day.ClientsTurnedAway
+		served += day.ClientsServed
+		waited += day.AverageWait * time.Duration(day.ClientsServed)
+		queueLength += day.queueLength
+	}
+
+	if arrived > 0 {
+		analysis.Simulated.Blocking = float64(turnedAway) / float64(arrived)
+	}
+	if served > 0 {
+		analysis.Simulated.Wait = waited / time.Duration(served)
+	}
+	if runs > 0 {
+		analysis.Simulated.QueueLength = queueLength / float64(runs)
+	}
+	return analysis, nil
+}
+
+// queueDay is how one simulated day of an M/M/c/K queue went.
+type queueDay struct {
+	Report
+	queueLength float64 // the average number of clients in the waiting room while the shop was open
+}
+
+// simulateQueue runs one day at a shop that is an M/M/c/K queue, on a simulated clock.
+func simulateQueue(model MMcK, timeOpen time.Duration, seed int64) (queueDay, error) {
+	rng := rand.New(rand.NewSource(seed))
+	serviceTime := time.Duration(float64(time.Second) / model.ServiceRate)
+	clock := NewSimClock(time.Date(2023, time.January, 2, 9, 0, 0, 0, time.UTC))
+	shop := &BarberShop{
+		ShopCapacity:      model.K - model.C,
+		HairCurDuration:   serviceTime,
+		BarberDoneChan:    make(chan bool),
+		Clock:             clock,
+		ChooseServiceTime: exponentialServiceTimes(rand.New(rand.NewSource(rng.Int63()))),
+	}
+	for ii := 0; ii < model.C; ii++ {
+		shop.addBarber(Barber{Name: fmt.Sprintf("Barber #%d", ii+1)})
+	}
+	arrivals := &PoissonArrivals{Mean: time.Duration(float64(time.Second) / model.ArrivalRate), Rand: rng}
+
+	// look into the waiting room often while the shop is open, from inside the simulation, so that looking
+	// happens at the moments the simulated clock says it does
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(timeOpen))
+	defer cancel()
+	var wait func() error
+	var err error
+	var samples, waiting int
+	opened, sampled := make(chan bool), make(chan bool)
+	clock.Go(func() {
+		wait, err = shop.start(ctx, arrivals)
+		if err == nil {
+			clock.Go(func() {
+				defer close(sampled)
+				for shop.isOpen() {
+					samples++
+					waiting += shop.waiting()
+					clock.Sleep(serviceTime / 20)
+				}
+			})
+		}
+		close(opened)
+	})
+	<-opened
+	if err != nil {
+		return queueDay{}, err
+	}
+	if err := wait(); err != nil {
+		return queueDay{}, err
+	}
+	<-sampled
+
+	day := queueDay{Report: shop.report()}
+	if samples > 0 {
+		day.queueLength = float64(waiting) / float64(samples)
+	}
+	return day, nil
+}
+
+// exponentialServiceTimes makes every service take an exponentially distributed time, with the service's usual
+// length as the average, drawn from rng. The barbers may draw at the same time, so the draws take turns.
+func exponentialServiceTimes(rng *rand.Rand) func(time.Duration) time.Duration {
+	var mutex sync.Mutex
+	return func(mean time.Duration) time.Duration {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return time.Duration(rng.ExpFloat64() * float64(mean))
+	}
+}
+
+// analysisText returns a table comparing queueing theory's predictions with the simulation.
+func analysisText(a QueueAnalysis) string {
+	var b strings.Builder
+	m := a.Model
+	fmt.Fprintf(&b, "M/M/c/K with c=%d %s and K=%d clients at most, %.2f arrivals/s, %.2f services/s per barber (load %.2f)\n",
+		m.C, plural(m.C, "barber"), m.K, m.ArrivalRate, m.ServiceRate, m.ArrivalRate/(float64(m.C)*m.ServiceRate))
+	fmt.Fprintf(&b, "simulated over %d days of %v\n", a.Runs, a.TimeOpen)
+	fmt.Fprintf(&b, "%-24s %12s %12s %12s\n", "", "Predicted", "Simulated", "Difference")
+	fmt.Fprintf(&b, "%-24s %11.2f%% %11.2f%% %+8.2f pts\n", "Blocking probability",
+		a.Predicted.Blocking*100, a.Simulated.Blocking*100, (a.Simulated.Blocking-a.Predicted.Blocking)*100)
+	fmt.Fprintf(&b, "%-24s %12.3f %12.3f %11s\n", "Expected queue length",
+		a.Predicted.QueueLength, a.Simulated.QueueLength, relativeDifference(a.Simulated.QueueLength, a.Predicted.QueueLength))
+	fmt.Fprintf(&b, "%-24s %12v %12v %11s\n", "Expected wait",
+		a.Predicted.Wait.Round(time.Millisecond), a.Simulated.Wait.Round(time.Millisecond),
+		relativeDifference(a.Simulated.Wait.Seconds(), a.Predicted.Wait.Seconds()))
+	return b.String()
+}
+
+// relativeDifference says how far got is from want, as a percentage of want.
+func relativeDifference(got, want float64) string {
+	if want == 0 {
+		if got == 0 {
+			return "0.0%"
+		}
+		return "n/a"
+	}
+	diff := (got - want) / want * 100
+	if math.Abs(diff) >= 1000 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", diff)
+}
+
+// printAnalysis prints a comparison of queueing theory with the simulation, the same way as the shop's own report.
+func printAnalysis(a QueueAnalysis) {
+	color.Green("---------------------------------------------------------------------")
+	for _, line := range strings.Split(strings.TrimSpace(analysisText(a)), "\n") {
+		color.Yellow(line)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func Test_MMcKPredictions(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		model       MMcK
+		blocking    float64
+		queueLength float64
+		wait        time.Duration
+	}{
+		{
+			// with arrivals exactly as fast as the barber, every number of clients is as likely as any other
+			name:        "one barber, fully loaded",
+			model:       MMcK{ArrivalRate: 1, ServiceRate: 1, C: 1, K: 4},
+			blocking:    0.2,
+			queueLength: 1.2,
+			wait:        1500 * time.Millisecond,
+		},
+		{
+			// with no waiting room, nobody waits, and the Erlang B formula says who is turned away
+			name:     "two barbers, no waiting room",
+			model:    MMcK{ArrivalRate: 1, ServiceRate: 1, C: 2, K: 2},
+			blocking: 0.2,
+		},
+	} {
+		got := test.model.predict()
+		if math.Abs(got.Blocking-test.blocking) > 1e-9 || math.Abs(got.QueueLength-test.queueLength) > 1e-9 {
+			t.Errorf("%s: expected blocking %v and queue length %v, but got %v and %v",
+				test.name, test.blocking, test.queueLength, got.Blocking, got.QueueLength)
+		}
+		if diff := got.Wait - test.wait; diff < -time.Microsecond || diff > time.Microsecond {
+			t.Errorf("%s: expected a wait of %v, but got %v", test.name, test.wait, got.Wait)
+		}
+	}
+}
+
+func Test_analyzeQueue(t *testing.T) {
+	// two barbers, three seats, and three quarters of the barbers' time taken up, over long enough days that the
+	// empty shop at the start of each day hardly matters
+	model := MMcK{ArrivalRate: 1, ServiceRate: 1 / 1.5, C: 2, K: 5}
+	analysis, err := analyzeQueue(model, 2000*time.Second, 5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	predicted, simulated := analysis.Predicted, analysis.Simulated
+	if math.Abs(simulated.Blocking-predicted.Blocking) > 0.02 {
+		t.Errorf("expected about %.3f of clients to be turned away, but %.3f were", predicted.Blocking, simulated.Blocking)
+	}
+	if math.Abs(simulated.QueueLength-predicted.QueueLength) > 0.1*predicted.QueueLength {
+		t.Errorf("expected an average of %.3f clients waiting, but got %.3f", predicted.QueueLength, simulated.QueueLength)
+	}
+	if math.Abs(float64(simulated.Wait-predicted.Wait)) > 0.1*float64(predicted.Wait) {
+		t.Errorf("expected an average wait of %v, but got %v", predicted.Wait, simulated.Wait)
+	}
+}
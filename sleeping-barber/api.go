@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Handler returns an HTTP API for the shop, so that it can be driven and watched without touching any Go code:
+//
+//	POST /clients  a client walks in; the JSON body may say who, and what they want
+//	GET  /status   what each barber is doing, and how full the waiting room is
+//	GET  /events   everything that happens in the shop from now on, as a stream of Server-Sent Events
+func (shop *BarberShop) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/clients", shop.handleClients)
+	mux.HandleFunc("/status", shop.handleStatus)
+	mux.HandleFunc("/events", shop.handleEvents)
+	return mux
+}
+
+// walkIn is the body of a POST to /clients. Every field may be left out.
+type walkIn struct {
+	Name     string      `json:"name"`
+	Service  Service     `json:"service"`
+	Class    ClientClass `json:"class"`
+	Patience float64     `json:"patience_seconds"`
+}
+
+// walkInResult is the response to a POST to /clients.
+type walkInResult struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Seated bool   `json:"seated"`
+}
+
+// shopStatus is the response to a GET of /status.
+type shopStatus struct {
+	State      ShopState      `json:"state"`
+	Open       bool           `json:"open"`
+	Now        time.Time      `json:"now"`                 // by the shop's clock
+	ClosesAt   time.Time      `json:"closes_at,omitempty"` // zero if the shop does not know when it closes
+	Waiting    int            `json:"waiting"`
+	Capacity   int            `json:"capacity"`
+	Served     int            `json:"served"`
+	TurnedAway int            `json:"turned_away"`
+	Reneged    int            `json:"reneged"`
+	Barbers    []barberStatus `json:"barbers"`
+}
+
+type barberStatus struct {
+	Name     string      `json:"name"`
+	State    BarberState `json:"state"`
+	Client   string      `json:"client,omitempty"` // who is in the barber's chair
+	Haircuts int         `json:"haircuts"`
+}
+
+// handleClients sends a walk-in into the shop. The client is seated with 201 Created, or turned away with 503
+// Service Unavailable if the shop is closed or full or nobody can do what the client wants.
+func (shop *BarberShop) handleClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req walkIn
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, fmt.Sprintf("bad client: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Class != "" && req.Class != ClassRegular && req.Class != ClassVIP {
+		http.Error(w, fmt.Sprintf("unknown class of client %q", req.Class), http.StatusBadRequest)
+		return
+	}
+	if req.Patience < 0 {
+		http.Error(w, "patience cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	client := shop.newClient()
+	if req.Name != "" {
+		client.Name = req.Name
+	}
+	if req.Service != "" {
+		client.Service = req.Service
+	}
+	if req.Class != "" {
+		client.Class = req.Class
+	}
+	client.Patience = time.Duration(req.Patience * float64(time.Second))
+
+	// the client walks in as part of the shop's day, whatever clock the shop goes by
+	done := make(chan bool)
+	shop.Clock.Go(func() {
+		shop.addClient(client)
+		close(done)
+	})
+	<-done
+
+	shop.mutex.Lock()
+	result := walkInResult{ID: client.ID, Name: client.Name, Seated: !client.Seated.IsZero()}
+	shop.mutex.Unlock()
+
+	status := http.StatusCreated
+	if !result.Seated {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, result)
+}
+
+// handleStatus reports what each barber is doing, and how full the waiting room is.
+func (shop *BarberShop) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, shop.status())
+}
+
+// status takes a snapshot of the shop.
+func (shop *BarberShop) status() shopStatus {
+	shop.mutex.Lock()
+	defer shop.mutex.Unlock()
+
+	status := shopStatus{
+		State:      shop.state,
+		Open:       shop.state == ShopOpen,
+		Now:        shop.Clock.Now(),
+		ClosesAt:   shop.closesAt,
+		Waiting:    shop.room.len(),
+		Capacity:   shop.ShopCapacity,
+		TurnedAway: shop.ClientsTurnedAway,
+		Reneged:    shop.ClientsReneged,
+		Barbers:    []barberStatus{},
+	}
+
+	shop.statsMutex.Lock()
+	defer shop.statsMutex.Unlock()
+	status.Served = len(shop.served)
+	for _, barber := range shop.barbers {
+		b := barberStatus{Name: barber.Name, State: barber.state, Haircuts: barber.cuts}
+		if barber.client != nil {
+			b.Client = barber.client.String()
+		}
+		status.Barbers = append(status.Barbers, b)
+	}
+	return status
+}
+
+// handleEvents streams the shop's events as Server-Sent Events, until the shop has closed and every barber has
+// gone home, or until the client hangs up.
+func (shop *BarberShop) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := shop.events.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Kind, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_API(t *testing.T) {
+	shop := newTestShop(5, 20*time.Millisecond)
+	shop.Clock = RealClock{}
+	shop.addBarber(Barber{Name: "Frank"})
+
+	server := httptest.NewServer(shop.Handler())
+	defer server.Close()
+
+	walkIn := func(body string) (int, walkInResult) {
+		t.Helper()
+		resp, err := http.Post(server.URL+"/clients", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var result walkInResult
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+	status := func() shopStatus {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var status shopStatus
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+		return status
+	}
+
+	// nobody gets in before the shop opens
+	if code, _ := walkIn(""); code != http.StatusServiceUnavailable {
+		t.Errorf("expected a client to be turned away before opening, but got %d", code)
+	}
+	if s := status(); s.Open || len(s.Barbers) != 1 || s.Barbers[0].State != BarberOffDuty {
+		t.Errorf("unexpected status before opening: %+v", s)
+	}
+
+	// watch everything that happens from here on
+	resp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected an event stream, but got %q", ct)
+	}
+	events := make(chan map[EventKind]int)
+	go func() {
+		counts := make(map[EventKind]int)
+		lines := bufio.NewScanner(resp.Body)
+		for lines.Scan() {
+			if kind := strings.TrimPrefix(lines.Text(), "event: "); kind != lines.Text() {
+				counts[EventKind(kind)]++
+			}
+		}
+		events <- counts
+	}()
+
+	// open the shop with nobody coming in but walk-ins, until we say otherwise
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- shop.Run(ctx, &TraceArrivals{})
+	}()
+	for !shop.isOpen() {
+		time.Sleep(time.Millisecond)
+	}
+
+	for ii := 0; ii < 3; ii++ {
+		if code, result := walkIn(`{"service": "cut", "class": "vip"}`); code != http.StatusCreated || !result.Seated {
+			t.Errorf("expected a walk-in to be seated, but got %d: %+v", code, result)
+		}
+	}
+	if code, _ := walkIn(`{"class": "royalty"}`); code != http.StatusBadRequest {
+		t.Errorf("expected an unknown class of client to be refused, but got %d", code)
+	}
+	if resp, err := http.Get(server.URL + "/clients"); err != nil {
+		t.Error(err)
+	} else {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("expected GET /clients not to be allowed, but got %d", resp.StatusCode)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the barber has finished up and gone home, and the event stream is over
+	if s := status(); s.Open || s.Waiting != 0 || s.Barbers[0].State != BarberHome || s.Barbers[0].Haircuts != 3 {
+		t.Errorf("unexpected status after closing: %+v", s)
+	}
+	counts := <-events
+	for kind, want := range map[EventKind]int{EventClientArrived: 3, EventCutStarted: 3, EventCutFinished: 3, EventBarberHome: 1} {
+		if counts[kind] != want {
+			t.Errorf("expected %d %s events, but got %d", want, kind, counts[kind])
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Appointment is a slot booked with a particular barber. The barber serves the client at the slot time, before
+// anyone who walked in, once done with whoever is in the chair. A client who is late keeps the slot for the
+// shop's AppointmentGrace; after that, the barber goes back to the walk-ins, and the client has to walk in too.
+type Appointment struct {
+	Name    string        // who booked the slot; empty means the client is numbered like everyone else
+	Barber  string        // the name of the barber the slot is with
+	At      time.Duration // when the slot is, measured from the moment the shop opens
+	Service Service       // what the client wants; empty means a cut
+	Late    time.Duration // how late the client turns up; negative means early
+	NoShow  bool          // the client never turns up at all
+}
+
+// booking is an appointment in the shop's book, and what became of it.
+type booking struct {
+	Appointment
+	client *Client
+	barber *barberStats
+	slot   time.Time
+	missed bool // the client was too late, or never came, so the slot is gone
+	held   bool // the barber has kept the slot free for the client, up to the end of the grace period
+}
+
+// bookAppointments puts every appointment in the book, with the barber it is booked with. The shop's clock
+// must already say when the shop opened.
+func (shop *BarberShop) bookAppointments() error {
+	shop.statsMutex.Lock()
+	barbers := make(map[string]*barberStats)
+	for _, barber := range shop.barbers {
+		barbers[barber.Name] = barber
+	}
+	shop.statsMutex.Unlock()
+
+	shop.mutex.Lock()
+	defer shop.mutex.Unlock()
+
+	for _, appointment := range shop.Appointments {
+		barber, ok := barbers[appointment.Barber]
+		if !ok {
+			return fmt.Errorf("appointment at %v is with %q, who does not work here", appointment.At, appointment.Barber)
+		}
+
+		shop.clients++
+		client := newClient(shop.clients)
+		if appointment.Name != "" {
+			client.Name = appointment.Name
+		}
+		if appointment.Service != "" {
+			client.Service = appointment.Service
+		}
+		client.Appointment = shop.opened.Add(appointment.At)
+
+		b := &booking{Appointment: appointment, client: client, barber: barber, slot: client.Appointment}
+		shop.bookings = append(shop.bookings, b)
+
+		// each barber sees the day's appointments in order
+		ii := len(barber.bookings)
+		for ii > 0 && barber.bookings[ii-1].slot.After(b.slot) {
+			ii--
+		}
+		barber.bookings = append(barber.bookings, nil)
+		copy(barber.bookings[ii+1:], barber.bookings[ii:])
+		barber.bookings[ii] = b
+	}
+	return nil
+}
+
+// sendInAppointments sends each client who booked an appointment into the shop when the client turns up.
+func (shop *BarberShop) sendInAppointments() {
+	shop.mutex.Lock()
+	bookings := shop.bookings
+	shop.mutex.Unlock()
+
+	for _, b := range bookings {
+		if b.NoShow {
+			continue
+		}
+		b := b
+		shop.Clock.Go(func() {
+			if arrive := b.At + b.Late; arrive > 0 {
+				shop.Clock.Sleep(arrive)
+			}
+			shop.arriveForAppointment(b)
+		})
+	}
+}
+
+// arriveForAppointment lets in a client who has booked an appointment. A client who arrives in time sits down to
+// wait for the barber, without taking up a seat meant for walk-ins; a client who has missed the slot, or whose
+// barber is not there to keep it, has to walk in like everyone else.
+func (shop *BarberShop) arriveForAppointment(b *booking) {
+	shop.mutex.Lock()
+	now := shop.Clock.Now()
+	if b.barber.home || b.barber.dismissed || b.barber.Schedule.over(shop.sinceOpening()) {
+		b.missed = true
+		shop.mutex.Unlock()
+		color.Red("%s has gone home, so %s has to walk in.", b.barber.Name, b.client)
+		shop.addClient(b.client)
+		return
+	}
+	if b.missed || now.After(b.slot.Add(shop.AppointmentGrace)) {
+		b.missed = true
+		shop.mutex.Unlock()
+		color.Red("%s is too late for the appointment with %s, and has to walk in.", b.client, b.barber.Name)
+		shop.addClient(b.client)
+		return
+	}
+	defer shop.mutex.Unlock()
+
+	b.client.Arrived = now
+	color.Green("*** %s arrives for the appointment with %s.", b.client, b.barber.Name)
+	shop.event(EventClientArrived, b.client, nil)
+
+	if shop.state != ShopOpen {
+		color.Red("The shop is closed, so %s leaves!", b.client)
+		shop.ClientsAfterHours++
+		shop.event(EventClientTurnedAway, b.client, nil)
+		b.missed = true
+		return
+	}
+
+	b.client.Seated = now
+
+	// a barber who is asleep, or keeping the slot free, sees to the client; one on a break finishes it first
+	shop.statsMutex.Lock()
+	state := b.barber.state
+	shop.statsMutex.Unlock()
+	if state == BarberSleeping || state == BarberExpecting {
+		shop.wakeUp(b.barber)
+	}
+}
+
+// appointmentFor returns the client the barber has an appointment with now, if that client is here. If the
+// client is late but still within the grace period, holding is true, and the barber should keep the slot free
+// until holdUntil. Appointments whose clients are too late are given up on, once the barber has kept the slot free
+// to the very end of the grace period, so that a client who turns up just then still keeps it. The caller must hold
+// the shop's mutex.
+func (shop *BarberShop) appointmentFor(barber *barberStats) (client *Client, holding bool, holdUntil time.Time) {
+	now := shop.Clock.Now()
+	for len(barber.bookings) > 0 {
+		b := barber.bookings[0]
+		switch {
+		case b.missed:
+			barber.bookings = barber.bookings[1:]
+
+		case !b.client.Seated.IsZero():
+			// the client is here, but may be early; at closing time, though, there is no point in waiting
+			if now.Before(b.slot) && !shop.doorsClosed() {
+				return nil, false, time.Time{}
+			}
+			barber.bookings = barber.bookings[1:]
+			return b.client, false, time.Time{}
+
+		case now.Before(b.slot):
+			return nil, false, time.Time{}
+
+		case shop.doorsClosed() || now.After(b.slot.Add(shop.AppointmentGrace)) ||
+			b.held && !now.Before(b.slot.Add(shop.AppointmentGrace)):
+			color.Red("%s did not turn up for the appointment with %s.", b.client, barber.Name)
+			b.missed = true
+			barber.bookings = barber.bookings[1:]
+
+		default:
+			b.held = true
+			return nil, true, b.slot.Add(shop.AppointmentGrace)
+		}
+	}
+	return nil, false, time.Time{}
+}
+
+// nextSlot returns when the barber's next appointment is, measured from the moment the shop opened, if it is
+// yet to come. The caller must hold the shop's mutex.
+func (shop *BarberShop) nextSlot(barber *barberStats) (time.Duration, bool) {
+	for _, b := range barber.bookings {
+		if !b.missed {
+			slot := b.slot.Sub(shop.opened)
+			return slot, slot > shop.sinceOpening()
+		}
+	}
+	return 0, false
+}
+
+// hasAppointmentWaiting reports whether a client with an appointment with the barber is waiting. The caller
+// must hold the shop's mutex.
+func (shop *BarberShop) hasAppointmentWaiting(barber *barberStats) bool {
+	for _, b := range barber.bookings {
+		if !b.missed && !b.client.Seated.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// AppointmentStats describes how the day's appointments went, both for the clients who booked them and for
+// everyone else.
+type AppointmentStats struct {
+	Booked          int
+	Kept            int           // appointments the client was served for
+	Missed          int           // appointments the client was too late for, or never turned up to
+	OnTime          int           // clients who turned up by the time of their appointment
+	AverageLateness time.Duration // how late clients with appointments turned up, for those who did; negative is early
+	AverageDelay    time.Duration // how long after the slot time the barber started on kept appointments
+	WalkInDelay     time.Duration // how long barbers kept walk-ins waiting, while serving or keeping slots free
+}
+
+// appointmentStats works out how the day's appointments went. The caller must hold the shop's mutex.
+func (shop *BarberShop) appointmentStats() AppointmentStats {
+	shop.statsMutex.Lock()
+	stats := AppointmentStats{Booked: len(shop.bookings), WalkInDelay: shop.delayed}
+	shop.statsMutex.Unlock()
+
+	var came int
+	var lateness, delay time.Duration
+	for _, b := range shop.bookings {
+		client := b.client
+		if !client.Arrived.IsZero() {
+			came++
+			lateness += client.Arrived.Sub(client.Appointment)
+			if !client.Arrived.After(client.Appointment) {
+				stats.OnTime++
+			}
+		}
+		if !b.missed && !client.CutStarted.IsZero() {
+			stats.Kept++
+			delay += client.CutStarted.Sub(client.Appointment)
+		}
+	}
+	stats.Missed = stats.Booked - stats.Kept
+
+	if came > 0 {
+		stats.AverageLateness = lateness / time.Duration(came)
+	}
+	if stats.Kept > 0 {
+		stats.AverageDelay = delay / time.Duration(stats.Kept)
+	}
+	return stats
+}
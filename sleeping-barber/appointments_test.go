@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_appointments(t *testing.T) {
+	shop := newTestShop(5, time.Second)
+	shop.AppointmentGrace = time.Second
+	shop.Appointments = []Appointment{
+		{Name: "Early", Barber: "Frank", At: 2 * time.Second, Late: -500 * time.Millisecond},
+		{Name: "Late", Barber: "Frank", At: 4 * time.Second, Late: 500 * time.Millisecond},
+		{Name: "Absent", Barber: "Frank", At: 6 * time.Second, NoShow: true},
+		{Name: "Very late", Barber: "Frank", At: 8 * time.Second, Late: 2 * time.Second},
+	}
+	shop.addBarber(Barber{Name: "Frank"})
+	runTestDay(t, shop, 12*time.Second, &TraceArrivals{Offsets: []time.Duration{
+		time.Second, 1200 * time.Millisecond, 1400 * time.Millisecond,
+	}})
+
+	// Frank is busy with the first walk-in until 2s, and then sees Early, who came at 1.5s, before the other two
+	// walk-ins. At 4s Frank keeps the slot free for Late, who comes at 4.5s, while the last walk-in waits. Absent
+	// never comes, and Very late comes long after the grace period is over, so has to walk in.
+	var order []string
+	for _, client := range shop.clientsServed() {
+		order = append(order, client.Name)
+	}
+	expected := []string{"Client #5", "Early", "Client #6", "Late", "Client #7", "Very late"}
+	if len(order) != len(expected) {
+		t.Fatalf("expected clients to be served in the order %v, but got %v", expected, order)
+	}
+	for ii := range expected {
+		if order[ii] != expected[ii] {
+			t.Fatalf("expected clients to be served in the order %v, but got %v", expected, order)
+		}
+	}
+
+	got := shop.report().Appointments
+	want := AppointmentStats{
+		Booked:          4,
+		Kept:            2,
+		Missed:          2,
+		OnTime:          1,
+		AverageLateness: 2 * time.Second / 3,
+		AverageDelay:    250 * time.Millisecond,
+		WalkInDelay:     2500 * time.Millisecond, // Early's cut, keeping Late's slot free, and Late's cut
+	}
+	if got != want {
+		t.Errorf("expected %+v but got %+v", want, got)
+	}
+}
+
+func Test_appointmentWithNobody(t *testing.T) {
+	shop := newTestShop(5, time.Second)
+	shop.Appointments = []Appointment{{Barber: "Nobody", At: time.Second}}
+	shop.addBarber(Barber{Name: "Frank"})
+
+	if err := shop.Run(context.Background(), &TraceArrivals{}); err == nil {
+		t.Error("expected an appointment with a barber who does not work here to keep the shop from opening")
+	}
+	if shop.isOpen() {
+		t.Error("expected the shop not to open")
+	}
+}
+
+func Test_appointmentAfterShift(t *testing.T) {
+	for _, bob := range []bool{true, false} {
+		shop := newTestShop(5, time.Second)
+		shop.AppointmentGrace = time.Second
+		shop.Appointments = []Appointment{{Name: "Stranded", Barber: "Frank", At: 5 * time.Second}}
+		shop.addBarber(Barber{Name: "Frank", Schedule: Schedule{End: 3 * time.Second}})
+		if bob {
+			shop.addBarber(Barber{Name: "Bob"})
+		}
+		runTestDay(t, shop, 10*time.Second, &TraceArrivals{})
+
+		// Frank has gone home by the time of the appointment, so the client walks in, and Bob, if he is there,
+		// serves the client instead
+		report := shop.report()
+		if bob && (report.ClientsServed != 1 || shop.clientsServed()[0].Barber != "Bob") {
+			t.Errorf("expected Bob to serve the client Frank was not there for, but %d clients were served",
+				report.ClientsServed)
+		}
+		if !bob && report.ClientsTurnedAway != 1 {
+			t.Errorf("expected the client to be turned away with nobody there to serve them, but %d were",
+				report.ClientsTurnedAway)
+		}
+		if report.Appointments.Missed != 1 {
+			t.Errorf("expected the appointment to be missed, but %d were", report.Appointments.Missed)
+		}
+	}
+}
+
+func Test_appointmentOnTimeWithoutGrace(t *testing.T) {
+	shop := newTestShop(5, time.Second)
+	shop.Appointments = []Appointment{
+		{Name: "Punctual", Barber: "Frank", At: 2 * time.Second},
+		{Name: "Absent", Barber: "Frank", At: 4 * time.Second, NoShow: true},
+	}
+	shop.addBarber(Barber{Name: "Frank"})
+	runTestDay(t, shop, 6*time.Second, &TraceArrivals{})
+
+	// with no grace at all, Frank gives up on Absent the moment the slot comes round
+	got := shop.report().Appointments
+	want := AppointmentStats{Booked: 2, Kept: 1, Missed: 1, OnTime: 1}
+	if got != want {
+		t.Errorf("expected a client who is right on time to keep the appointment, %+v, but got %+v", want, got)
+	}
+}
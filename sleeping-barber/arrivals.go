@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArrivalProcess decides when clients walk into the shop. Different processes give the same average
+// arrival rate very different shapes, which matters a great deal for how often the waiting room fills up.
+type ArrivalProcess interface {
+	// Next returns how long to wait before the next client arrives. ok is false once no more clients will come.
+	Next() (gap time.Duration, ok bool)
+}
+
+// FixedArrivals sends in a client exactly every Interval.
+type FixedArrivals struct {
+	Interval time.Duration
+}
+
+func (a *FixedArrivals) Next() (time.Duration, bool) {
+	return a.Interval, true
+}
+
+// UniformArrivals waits anywhere between zero and twice Mean between clients, with every gap equally likely.
+type UniformArrivals struct {
+	Mean time.Duration
+	Rand *rand.Rand
+}
+
+func (a *UniformArrivals) Next() (time.Duration, bool) {
+	if a.Mean <= 0 {
+		return 0, true
+	}
+	return time.Duration(a.Rand.Int63n(int64(2 * a.Mean))), true
+}
+
+// PoissonArrivals is a Poisson process: the gaps between clients are exponentially distributed with the
+// given Mean, so clients arrive independently of each other.
+type PoissonArrivals struct {
+	Mean time.Duration
+	Rand *rand.Rand
+}
+
+func (a *PoissonArrivals) Next() (time.Duration, bool) {
+	return time.Duration(a.Rand.ExpFloat64() * float64(a.Mean)), true
+}
+
+// BurstyArrivals alternates between busy periods of length On, when clients arrive as a Poisson process
+// with mean gap OnMean, and quiet periods of length Off, with mean gap OffMean. An OffMean of zero means
+// nobody arrives during the quiet periods.
+type BurstyArrivals struct {
+	On      time.Duration
+	Off     time.Duration
+	OnMean  time.Duration
+	OffMean time.Duration
+	Rand    *rand.Rand
+
+	elapsed time.Duration // how far into the current on/off cycle the last client arrived
+}
+
+func (a *BurstyArrivals) Next() (time.Duration, bool) {
+	cycle := a.On + a.Off
+	if cycle <= 0 || a.OnMean <= 0 {
+		return 0, false
+	}
+
+	var gap time.Duration
+	for {
+		// which phase are we in, and when does it end?
+		mean, phaseEnd := a.OnMean, a.On
+		if a.elapsed >= a.On {
+			mean, phaseEnd = a.OffMean, cycle
+		}
+
+		// nobody comes during this phase, so skip straight to the end of it
+		if mean <= 0 {
+			gap += phaseEnd - a.elapsed
+			a.elapsed = 0
+			continue
+		}
+
+		// arrivals are memoryless, so if the next one would fall in the following phase we can simply
+		// move to the start of that phase and draw again at its rate
+		next := time.Duration(a.Rand.ExpFloat64() * float64(mean))
+		if a.elapsed+next < phaseEnd {
+			a.elapsed += next
+			return gap + next, true
+		}
+		gap += phaseEnd - a.elapsed
+		a.elapsed = phaseEnd % cycle
+	}
+}
+
+// TraceArrivals replays a recorded list of arrival times, each one measured from the moment the shop opened.
+type TraceArrivals struct {
+	Offsets []time.Duration
+
+	next int
+	last time.Duration
+}
+
+func (a *TraceArrivals) Next() (time.Duration, bool) {
+	if a.next >= len(a.Offsets) {
+		return 0, false
+	}
+	gap := a.Offsets[a.next] - a.last
+	a.last = a.Offsets[a.next]
+	a.next++
+	return gap, true
+}
+
+// readArrivalTrace reads arrival times from the first column of a CSV file. Each time is either a Go
+// duration such as "1.5s" or a plain number of seconds; a header row is skipped, and times must not go backwards.
+func readArrivalTrace(r io.Reader) (*TraceArrivals, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	trace := &TraceArrivals{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := strings.TrimSpace(record[0])
+		offset, err := parseOffset(field)
+		if err != nil {
+			// the first line is allowed to be a header
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid arrival time %q", line, field)
+		}
+
+		if n := len(trace.Offsets); n > 0 && offset < trace.Offsets[n-1] {
+			return nil, fmt.Errorf("line %d: arrival time %v is earlier than the one before it", line, offset)
+		}
+		trace.Offsets = append(trace.Offsets, offset)
+	}
+
+	return trace, nil
+}
+
+// parseOffset parses either a Go duration or a number of seconds.
+func parseOffset(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		if d < 0 {
+			return 0, fmt.Errorf("negative arrival time %v", d)
+		}
+		return d, nil
+	}
+
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if seconds < 0 {
+		return 0, fmt.Errorf("negative arrival time %v", seconds)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// newArrivalProcess returns the named arrival process with the given average gap between clients.
+// The bursty process packs the same number of clients into busy periods of a quarter of the time, and the
+// trace process reads its arrivals from traceFile.
+func newArrivalProcess(kind string, mean time.Duration, traceFile string, rng *rand.Rand) (ArrivalProcess, error) {
+	switch kind {
+	case "fixed":
+		return &FixedArrivals{Interval: mean}, nil
+	case "uniform":
+		return &UniformArrivals{Mean: mean, Rand: rng}, nil
+	case "poisson":
+		return &PoissonArrivals{Mean: mean, Rand: rng}, nil
+	case "bursty":
+		return &BurstyArrivals{
+			On:     10 * mean,
+			Off:    30 * mean,
+			OnMean: mean / 4,
+			Rand:   rng,
+		}, nil
+	case "trace":
+		f, err := os.Open(traceFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		trace, err := readArrivalTrace(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", traceFile, err)
+		}
+		return trace, nil
+	default:
+		return nil, fmt.Errorf("unknown arrival process %q", kind)
+	}
+}
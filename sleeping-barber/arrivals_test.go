@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_readArrivalTrace(t *testing.T) {
+	trace, err := readArrivalTrace(strings.NewReader("arrived\n0.5\n1s\n1.25,ignored\n3s\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []time.Duration{500 * time.Millisecond, 500 * time.Millisecond, 250 * time.Millisecond, 1750 * time.Millisecond}
+	for ii, want := range expected {
+		gap, ok := trace.Next()
+		if !ok || gap != want {
+			t.Errorf("gap %d: expected %v but got %v (ok=%v)", ii, want, gap, ok)
+		}
+	}
+	if _, ok := trace.Next(); ok {
+		t.Error("expected the trace to be exhausted")
+	}
+
+	if _, err := readArrivalTrace(strings.NewReader("2s\n1s\n")); err == nil {
+		t.Error("expected an error for arrival times that go backwards")
+	}
+}
+
+func Test_BurstyArrivals(t *testing.T) {
+	bursty := &BurstyArrivals{
+		On:     time.Second,
+		Off:    3 * time.Second,
+		OnMean: 10 * time.Millisecond,
+		Rand:   rand.New(rand.NewSource(1)),
+	}
+
+	// nobody should ever arrive during the quiet part of a cycle
+	var now time.Duration
+	for ii := 0; ii < 1000; ii++ {
+		gap, ok := bursty.Next()
+		if !ok {
+			t.Fatal("bursty arrivals should never run out")
+		}
+		now += gap
+		if now%(4*time.Second) >= time.Second {
+			t.Fatalf("client arrived at %v, during a quiet period", now)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// AutoscalePolicy tells the shop's supervisor when to call in extra barbers and when to send them home again.
+// The supervisor looks at the waiting room every Interval. If it has been at least HighWater full (as a fraction
+// of ShopCapacity) for Sustain checks in a row, another barber is called in; if it has been at most LowWater full
+// for Sustain checks in a row, the barber who was called in last is sent home once done with the current client.
+type AutoscalePolicy struct {
+	Interval   time.Duration
+	HighWater  float64
+	LowWater   float64
+	Sustain    int
+	MinBarbers int    // never fewer barbers than this at work; at least one
+	MaxBarbers int    // never more barbers than this at work; zero means no limit
+	Hire       Barber // what the barbers who are called in can do; their names are numbered
+}
+
+// supervise runs the shop's autoscaling policy until closing time.
+func (shop *BarberShop) supervise(policy AutoscalePolicy) {
+	if policy.Interval <= 0 || shop.ShopCapacity <= 0 {
+		return
+	}
+	if policy.Sustain < 1 {
+		policy.Sustain = 1
+	}
+	if policy.MinBarbers < 1 {
+		policy.MinBarbers = 1
+	}
+
+	above, below := 0, 0
+	for {
+		shop.Clock.Sleep(policy.Interval)
+
+		// look at the waiting room, and call someone in if need be, all in one go, so that the shop cannot close
+		// in between
+		shop.mutex.Lock()
+		if shop.doorsClosed() {
+			shop.mutex.Unlock()
+			return
+		}
+		full := float64(shop.room.len()) / float64(shop.ShopCapacity)
+		onDuty := shop.onDuty()
+
+		// how long has the waiting room been this full, or this empty?
+		switch {
+		case full >= policy.HighWater:
+			above, below = above+1, 0
+		case full <= policy.LowWater:
+			above, below = 0, below+1
+		default:
+			above, below = 0, 0
+		}
+
+		if above >= policy.Sustain && (policy.MaxBarbers == 0 || onDuty < policy.MaxBarbers) {
+			above = 0
+			shop.BarbersHired++
+			hire := policy.Hire
+			hire.Name = fmt.Sprintf("%s #%d", policy.Hire.Name, shop.BarbersHired)
+			color.Cyan("The waiting room is filling up, so %s is called in.", hire.Name)
+			shop.takeOn(hire)
+		}
+		shop.mutex.Unlock()
+
+		if below >= policy.Sustain && onDuty > policy.MinBarbers {
+			below = 0
+			shop.sendHomeEarly()
+		}
+	}
+}
+
+// onDuty counts the barbers who are at work and have not been told to go home. The caller must hold the
+// shop's mutex.
+func (shop *BarberShop) onDuty() int {
+	shop.statsMutex.Lock()
+	defer shop.statsMutex.Unlock()
+
+	count := 0
+	for _, barber := range shop.barbers {
+		if !barber.home && !barber.dismissed {
+			count++
+		}
+	}
+	return count
+}
+
+// sendHomeEarly sends home the barber who started work last, once the barber is done with the current client.
+func (shop *BarberShop) sendHomeEarly() {
+	shop.mutex.Lock()
+	defer shop.mutex.Unlock()
+
+	shop.statsMutex.Lock()
+	var barber *barberStats
+	for ii := len(shop.barbers) - 1; ii >= 0; ii-- {
+		if !shop.barbers[ii].home && !shop.barbers[ii].dismissed {
+			barber = shop.barbers[ii]
+			break
+		}
+	}
+	shop.statsMutex.Unlock()
+	if barber == nil {
+		return
+	}
+
+	color.Cyan("The waiting room is quiet, so %s can go home early.", barber.Name)
+	barber.dismissed = true
+	shop.BarbersSentHome++
+
+	// a barber who is asleep has to be woken up first
+	shop.wakeUp(barber)
+}
@@ -1,58 +1,741 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
 )
 
+// ErrGracePeriodExceeded is returned by Run when barbers are still at work GracePeriod after closing time.
+var ErrGracePeriodExceeded = errors.New("barbers did not go home within the grace period")
+
 type BarberShop struct {
-	ShopCapacity    int
-	HairCurDuration time.Duration
-	NumberOfBarbers int
-	BarberDoneChan  chan bool
-	ClientChan      chan string
-	Open            bool
+	ShopCapacity      int
+	HairCurDuration   time.Duration
+	NumberOfBarbers   int
+	BarberDoneChan    chan bool
+	GracePeriod       time.Duration // how long the barbers may take to go home after closing time; zero means forever
+	ClientsTurnedAway int
+	ClientsAfterHours int
+	ClientsReneged    int
+	BarbersHired      int // barbers called in by the supervisor during the day
+	BarbersSentHome   int // barbers sent home early by the supervisor
+	Clock             Clock
+	Menu              map[Service]time.Duration         // how long each service takes; a cut takes HairCurDuration if it is missing
+	ChooseService     func() Service                    // picks what each new client asks for; nil means everyone wants a cut
+	ChoosePatience    func() time.Duration              // picks how patient each new client is; nil means everyone waits forever
+	ChooseClass       func() ClientClass                // picks whether each new client is a VIP; nil means nobody is
+	AgingThreshold    time.Duration                     // how long a regular client waits before being served like a VIP; zero means never
+	Appointments      []Appointment                     // the day's appointment book
+	AppointmentGrace  time.Duration                     // how late a client with an appointment may be and still keep the slot
+	Autoscaling       *AutoscalePolicy                  // when to call in extra barbers; nil means the barbers added up front are all there is
+	EventLog          io.Writer                         // where every event in the shop is written as a line of JSON; nil means nowhere
+	Prices            map[Service]Cents                 // what each service costs; anything missing is free
+	ChooseTip         func(*Client) Cents               // picks what each new client will tip, if served; nil means nobody tips
+	ChooseServiceTime func(time.Duration) time.Duration // picks how long a service takes, given its average; nil means always the average
+	RestartBackoff    time.Duration                     // how long a barber who panics takes to get back to work, at first; zero means 100ms
+
+	mutex     sync.Mutex     // protects NumberOfBarbers, the counters, and everything below
+	state     ShopState      // where the shop is in its day
+	observers stateObservers // everyone watching for the shop's state to change
+	opened    time.Time      // when the shop opened, which barbers' schedules are measured from
+	closesAt  time.Time      // when the doors close, if the shop knows
+	atWork    int            // barbers who have not gone home yet
+	room      waitingRoom    // clients waiting for a barber
+	napping   []*barberStats // barbers asleep in their chairs, in the order they fell asleep
+	resting   []*barberStats // barbers waiting for their shift to start or on a break
+	clients   int            // clients who have walked in so far, so that each gets a number
+	bookings  []*booking     // the appointment book, and what became of each appointment
+
+	statsMutex sync.Mutex // protects served and barbers, which every barber updates
+	served     []*Client
+	barbers    []*barberStats
+	delayed    time.Duration // how long barbers kept walk-ins waiting because of appointments
+
+	ledger   ledger     // what every client paid, and what the shop lost
+	events   eventBus   // tells anyone who is watching what goes on in the shop
+	logMutex sync.Mutex // protects EventLog, so that events are written one at a time
+	logErr   error      // why the event log could not be written, after which it is not written any more
+}
+
+// barberStats is what the shop knows about one of its barbers, and how the barber spent the day.
+type barberStats struct {
+	Barber
+	id       int // the barber's number, in the order the barbers were added
+	started  time.Time
+	wentHome time.Time
+	busy     time.Duration
+	napping  time.Duration
+	breaks   time.Duration
+	cuts     int
+	panics   int
+	state    BarberState
+	client   *Client // the client in the barber's chair, if any
+
+	bookings  []*booking // the barber's appointments that are yet to come, in order; protected by the shop's mutex
+	wake      chan bool  // receives a value when a client (or closing time) wakes the barber up
+	dismissed bool       // the barber has been told to go home early; protected by the shop's mutex
+	home      bool       // the barber has gone home; protected by the shop's mutex
+}
+
+// BarberState is what a barber is doing at the moment.
+type BarberState string
+
+const (
+	BarberOffDuty    BarberState = "off_duty" // the barber's shift has not started yet
+	BarberAwake      BarberState = "awake"    // the barber is looking for the next client
+	BarberSleeping   BarberState = "sleeping"
+	BarberCutting    BarberState = "cutting"
+	BarberOnBreak    BarberState = "on_break"
+	BarberExpecting  BarberState = "expecting"  // the barber is keeping a slot free for a client who is late
+	BarberRecovering BarberState = "recovering" // the barber panicked, and is pulling together before getting back to work
+	BarberHome       BarberState = "home"
+)
+
+// maxRestartBackoff is the longest a barber who keeps panicking is kept away from work at a time.
+const maxRestartBackoff = 30 * time.Second
+
+// setState records what the barber is doing now.
+func (shop *BarberShop) setState(barber *barberStats, state BarberState) {
+	shop.statsMutex.Lock()
+	barber.state = state
+	shop.statsMutex.Unlock()
 }
 
-func (shop *BarberShop) addBarber(barber string) {
+// addBarber puts a barber to work, and reports whether the barber was taken on. Barbers added before the shop
+// opens start work when it opens, or when their schedules say so, and take a nap until the first client arrives.
+// Once the doors have closed, nobody new starts work.
+func (shop *BarberShop) addBarber(barber Barber) bool {
+	shop.mutex.Lock()
+	defer shop.mutex.Unlock()
+	return shop.takeOn(barber)
+}
+
+// takeOn puts a barber to work, as addBarber does. The caller must hold the shop's mutex, so that the shop
+// cannot close, and stop counting its barbers, between deciding to take the barber on and doing so.
+func (shop *BarberShop) takeOn(barber Barber) bool {
+	if shop.doorsClosed() {
+		return false
+	}
+
+	stats := &barberStats{Barber: barber, state: BarberOffDuty, wake: make(chan bool, 1)}
+	shop.statsMutex.Lock()
+	stats.id = len(shop.barbers) + 1
+	shop.barbers = append(shop.barbers, stats)
+	shop.statsMutex.Unlock()
+
 	shop.NumberOfBarbers++
+	shop.atWork++
+	if !shop.opened.IsZero() {
+		shop.startBarber(stats)
+	}
+	return true
+}
 
-	go func() {
-		isSleeping := false
-		color.Yellow("%s goes to the waiting room to check for clients.", barber)
+// startBarber sends a barber to work until the barber goes home. A barber who panics does not take the whole shop
+// down: the barber is sent back to work after a while, and after twice as long each time it happens again. A
+// barber who then works for at least as long as that without panicking is over it, and the next time it happens
+// is back to being the first.
+func (shop *BarberShop) startBarber(stats *barberStats) {
+	shop.Clock.Go(func() {
+		first := shop.RestartBackoff
+		if first <= 0 {
+			first = 100 * time.Millisecond
+		}
+		backoff := first
 		for {
-			// if there are no clients, the barber goes to sleep
-			if len(shop.ClientChan) == 0 {
-				color.Yellow("There is nothing to do, so %s takes a nap.", barber)
-				isSleeping = true
+			started := shop.Clock.Now()
+			if shop.work(stats) {
+				return
+			}
+			if shop.Clock.Now().Sub(started) >= backoff {
+				backoff = first
+			}
+			shop.Clock.Sleep(backoff)
+			if backoff *= 2; backoff > maxRestartBackoff {
+				backoff = maxRestartBackoff
 			}
+		}
+	})
+}
 
-			client, shopOpen := <-shop.ClientChan
-			if shopOpen {
-				if isSleeping {
-					color.Yellow("%s wakes %s up.", client, barber)
-					isSleeping = false
-				}
-				// cut hair
-				shop.cutHair(barber, client)
-			} else {
-				// shop is closed, so send the barber home and close the go routine
-				shop.sendBarberHome(barber)
+// work has the barber serve clients until it is time to go home, and reports whether the barber went home. If
+// the barber panics instead, the client in the barber's chair goes back to the front of the line, and work
+// returns false. The shop's own bookkeeping is done holding the shop's mutex, which nobody could get back from a
+// barber who panicked while holding it, so it is kept simple enough not to panic.
+func (shop *BarberShop) work(barber *barberStats) (wentHome bool) {
+	shop.mutex.Lock()
+	home := barber.home
+	shop.mutex.Unlock()
+	if home {
+		// the barber panicked on the way out of the door, and has already said goodbye
+		return true
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			shop.barberPanicked(barber, r)
+		}
+	}()
+
+	for {
+		client, shopOpen := shop.nextClient(barber)
+		if !shopOpen {
+			// shop is closed, or the barber's shift is over, so send the barber home
+			shop.sendBarberHome(barber)
+			return true
+		}
+		shop.cutHair(barber, client)
+	}
+}
+
+// barberPanicked cleans up after a barber who panicked with r: the failure is logged, and the client the barber
+// was serving, if any, gets up and goes back to the front of the line, where another barber may pick the client up.
+// The client's seat may have been taken in the meantime, so the client is put back without one.
+func (shop *BarberShop) barberPanicked(barber *barberStats, r any) {
+	shop.statsMutex.Lock()
+	client := barber.client
+	barber.client = nil
+	barber.panics++
+	barber.state = BarberRecovering
+	shop.statsMutex.Unlock()
+
+	color.Red("*** %s panicked: %v", barber.Name, r)
+	shop.event(EventBarberPanicked, client, barber)
+	if client == nil {
+		return
+	}
+
+	client.Barber = ""
+	client.CutStarted = time.Time{}
+	shop.mutex.Lock()
+	defer shop.mutex.Unlock()
+	shop.room.putBack(client)
+	color.Yellow("%s goes back to the front of the line.", client)
+	shop.wakeBarber(client)
+}
+
+// nextClient takes the next client the barber can serve from the waiting room. If there is nobody the barber
+// can serve, the barber goes to sleep until a suitable client arrives and wakes the barber up, or until it is time
+// for a break. Once the shop has closed and nobody the barber can serve is left waiting, or once the barber's shift
+// is over, shopOpen is false.
+func (shop *BarberShop) nextClient(barber *barberStats) (client *Client, shopOpen bool) {
+	isSleeping := false
+	for {
+		shop.mutex.Lock()
+		if !shop.checkSchedule(barber) {
+			shop.mutex.Unlock()
+			return nil, false
+		}
+
+		// clients with appointments go first, and a barber whose client is late keeps the slot free for a while
+		client, holding, holdUntil := shop.appointmentFor(barber)
+		if client != nil {
+			client.delaysWalkIns = shop.room.waitingFor(barber.Barber)
+			shop.mutex.Unlock()
+			return client, true
+		}
+		if holding {
+			color.Yellow("%s is late, so %s keeps the slot free.", barber.bookings[0].client, barber.Name)
+			delaysWalkIns := shop.room.waitingFor(barber.Barber)
+			held := shop.restUntil(barber, holdUntil.Sub(shop.opened), BarberExpecting)
+			if delaysWalkIns {
+				shop.statsMutex.Lock()
+				shop.delayed += held
+				shop.statsMutex.Unlock()
+			}
+			shop.mutex.Unlock()
+			continue
+		}
+
+		if client = shop.room.takeFor(barber.Barber, shop.Clock.Now(), shop.AgingThreshold); client != nil {
+			if client.stopWaiting != nil {
+				client.stopWaiting()
+			}
+			shop.mutex.Unlock()
+			if isSleeping {
+				color.Yellow("%s wakes %s up.", client, barber.Name)
+			}
+			return client, true
+		}
+		if shop.doorsClosed() {
+			shop.mutex.Unlock()
+			return nil, false
+		}
+
+		// if there are no clients, the barber goes to sleep
+		color.Yellow("There is nothing to do, so %s takes a nap.", barber.Name)
+		isSleeping = true
+		shop.setState(barber, BarberSleeping)
+		shop.event(EventBarberNapping, nil, barber)
+		napStarted := shop.Clock.Now()
+		shop.napping = append(shop.napping, barber)
+		stop := func() bool { return false }
+		next, ok := barber.Schedule.nextChange(shop.sinceOpening())
+		if slot, booked := shop.nextSlot(barber); booked && (!ok || slot < next) {
+			next, ok = slot, true
+		}
+		if ok {
+			// wake up in time for the next break, appointment, or the end of the shift
+			stop = shop.Clock.AfterFunc(next-shop.sinceOpening(), func() {
+				shop.mutex.Lock()
+				defer shop.mutex.Unlock()
+				shop.wakeUp(barber)
+			})
+		}
+		shop.Clock.park()
+		shop.mutex.Unlock()
+
+		<-barber.wake
+		stop()
+
+		// whatever woke the barber up, the nap is over
+		shop.statsMutex.Lock()
+		barber.napping += shop.Clock.Now().Sub(napStarted)
+		barber.state = BarberAwake
+		shop.statsMutex.Unlock()
+		shop.event(EventBarberWoken, nil, barber)
+	}
+}
+
+// wakeBarber wakes up the barber who has been asleep the longest among those who can serve the client, if
+// any of them is asleep. A nil client wakes up whoever has been asleep the longest. The caller must hold the
+// shop's mutex.
+func (shop *BarberShop) wakeBarber(client *Client) {
+	for ii, barber := range shop.napping {
+		if client == nil || barber.canServe(client) {
+			shop.napping = append(shop.napping[:ii], shop.napping[ii+1:]...)
+			shop.Clock.unpark()
+			barber.wake <- true
+			return
+		}
+	}
+}
+
+func (shop *BarberShop) cutHair(barber *barberStats, client *Client) {
+	client.Barber = barber.Name
+	client.CutStarted = shop.Clock.Now()
+	shop.statsMutex.Lock()
+	barber.state = BarberCutting
+	barber.client = client
+	shop.statsMutex.Unlock()
+	shop.event(EventCutStarted, client, barber)
+	if client.Service == ServiceCut {
+		color.Green("%s is cutting %s's hair.", barber.Name, client)
+	} else {
+		color.Green("%s is giving %s a %s.", barber.Name, client, client.Service)
+	}
+	duration := barber.durationOf(shop.serviceDuration(client.Service))
+	if shop.ChooseServiceTime != nil {
+		duration = shop.ChooseServiceTime(duration)
+	}
+	shop.Clock.Sleep(duration)
+	client.CutFinished = shop.Clock.Now()
+	client.Price = shop.price(client.Service)
+	color.Green("%s is finished with %s.", barber.Name, client)
+
+	// once the client has been served, the client stays served, whatever happens to the barber afterwards
+	shop.statsMutex.Lock()
+	shop.served = append(shop.served, client)
+	if client.delaysWalkIns {
+		shop.delayed += client.ServiceTime()
+	}
+	barber.busy += client.ServiceTime()
+	barber.cuts++
+	barber.state = BarberAwake
+	barber.client = nil
+	shop.statsMutex.Unlock()
+	shop.ledger.paid(client.CutFinished, barber.Name, client.Service, client.Price, client.Tip)
+	shop.event(EventCutFinished, client, barber)
+}
+
+// clientsServed returns every client whose haircut has been finished so far, in the order they were finished.
+func (shop *BarberShop) clientsServed() []*Client {
+	shop.statsMutex.Lock()
+	defer shop.statsMutex.Unlock()
+
+	served := make([]*Client, len(shop.served))
+	copy(served, shop.served)
+	return served
+}
+
+func (shop *BarberShop) sendBarberHome(barber *barberStats) {
+	color.Cyan("%s is going home.", barber.Name)
+
+	shop.statsMutex.Lock()
+	barber.wentHome = shop.Clock.Now()
+	if barber.started.IsZero() {
+		// the barber never made it to work
+		barber.started = barber.wentHome
+	}
+	barber.state = BarberHome
+	shop.statsMutex.Unlock()
+	shop.event(EventBarberHome, nil, barber)
+
+	shop.mutex.Lock()
+	shop.atWork--
+	barber.home = true
+	shop.mutex.Unlock()
+
+	shop.BarberDoneChan <- true
+}
+
+// Run opens the shop and keeps it open until ctx's deadline, as measured by the shop's clock, or until ctx is
+// cancelled, sending in clients as the arrival process dictates. It returns once every barber has gone home, or
+// with ErrGracePeriodExceeded if some of them are still at work GracePeriod after closing time. The shop does not
+// open at all if its appointment book does not make sense.
+func (shop *BarberShop) Run(ctx context.Context, arrivals ArrivalProcess) error {
+	wait, err := shop.start(ctx, arrivals)
+	if err != nil {
+		return err
+	}
+	return wait()
+}
+
+// start does the work of Run without waiting for the day to be over: it opens the shop and sets the day in motion,
+// and returns a function that waits for the barbers to go home, as Run does.
+func (shop *BarberShop) start(ctx context.Context, arrivals ArrivalProcess) (wait func() error, err error) {
+	closing := make(chan bool)
+	late := make(chan int, 1)
+	stopGracePeriod := func() bool { return false }
+	var closeOnce sync.Once
+	closeShop := func() {
+		closeOnce.Do(func() {
+			shop.stopTakingClients()
+
+			// the grace period is measured by the shop's clock, from the moment the doors close, so it is the
+			// barbers who are still at work by then that are late, not the ones who just haven't told us yet
+			if shop.GracePeriod > 0 {
+				stopGracePeriod = shop.Clock.AfterFunc(shop.GracePeriod, func() {
+					shop.mutex.Lock()
+					atWork := shop.atWork
+					shop.mutex.Unlock()
+					if atWork > 0 {
+						late <- atWork
+					}
+				})
+			}
+
+			close(closing)
+		})
+	}
+
+	shop.mutex.Lock()
+	err = shop.setShopState(ShopOpening)
+	shop.opened = shop.Clock.Now()
+	shop.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if err := shop.bookAppointments(); err != nil {
+		shop.mutex.Lock()
+		shop.setShopState(ShopClosed)
+		shop.mutex.Unlock()
+		return nil, err
+	}
+
+	// watch for the end of the day from the moment the shop opens, so as not to miss it
+	changes, unsubscribe := shop.watchState()
+	shop.mutex.Lock()
+	shop.setShopState(ShopOpen)
+	shop.mutex.Unlock()
+
+	// set everything up from inside the simulation, so that the day starts at the same moment for everyone
+	shop.Clock.Go(func() {
+		// send in the barbers who were added before the shop opened
+		shop.statsMutex.Lock()
+		barbers := make([]*barberStats, len(shop.barbers))
+		copy(barbers, shop.barbers)
+		shop.statsMutex.Unlock()
+		for _, barber := range barbers {
+			shop.startBarber(barber)
+		}
+
+		// close the doors right on time, even though the barbers may be busy for a while yet
+		if deadline, ok := ctx.Deadline(); ok {
+			shop.mutex.Lock()
+			shop.closesAt = deadline
+			shop.mutex.Unlock()
+			shop.Clock.AfterFunc(deadline.Sub(shop.Clock.Now()), closeShop)
+		}
+
+		// add clients, both those who walk in and those who have booked
+		shop.Clock.Go(func() {
+			shop.sendInClients(arrivals)
+		})
+		shop.sendInAppointments()
+
+		// keep an eye on the waiting room, and call in extra barbers when it gets busy
+		if shop.Autoscaling != nil {
+			shop.Clock.Go(func() {
+				shop.supervise(*shop.Autoscaling)
+			})
+		}
+	})
+
+	// count the barbers as they go home, all day long, since some of them may be sent home early, and close the
+	// shop for the day once the doors are closed and the last of them has gone
+	go func() {
+		shopClosing := closing
+		for gone := 0; ; {
+			shop.mutex.Lock()
+			allGone := shop.state == ShopClosing && gone == shop.NumberOfBarbers
+			if allGone {
+				shop.setShopState(ShopClosed)
+			}
+			shop.mutex.Unlock()
+			if allGone {
+				close(shop.BarberDoneChan)
 				return
 			}
+
+			select {
+			case <-shop.BarberDoneChan:
+				gone++
+			case <-shopClosing:
+				shopClosing = nil
+			}
 		}
+	}()
 
+	// closing early is up to whoever cancels the context; closing on time is up to the shop's clock, which may
+	// not be the wall clock that the context's deadline goes by
+	go func() {
+		select {
+		case <-closing:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.Canceled) {
+				closeShop()
+			}
+		}
 	}()
+
+	return func() error {
+		// block until the barbershop is closed
+		<-closing
+		defer stopGracePeriod()
+		defer shop.events.close()
+		defer unsubscribe()
+		return shop.waitForBarbers(changes, late)
+	}, nil
 }
 
-func (shop *BarberShop) cutHair(barber, client string) {
-	color.Green("%s is cutting %s's hair.", barber, client)
-	time.Sleep(shop.HairCurDuration)
-	color.Green("%s is finished cutting %s's hair.", barber, client)
+// sendInClients sends clients into the shop as the arrival process dictates, until the shop closes or no
+// more clients are coming. It stops waiting for the next client the moment the doors close, however long the
+// wait was going to be.
+func (shop *BarberShop) sendInClients(arrivals ArrivalProcess) {
+	changes, unsubscribe := shop.watchState()
+	defer unsubscribe()
+
+	for {
+		// wait for the next client, unless nobody else is coming
+		gap, ok := arrivals.Next()
+		if !ok {
+			return
+		}
+		if !shop.waitForClient(gap, changes) || !shop.isOpen() {
+			return
+		}
+		client := shop.newClient()
+		if shop.ChooseService != nil {
+			client.Service = shop.ChooseService()
+		}
+		if shop.ChoosePatience != nil {
+			client.Patience = shop.ChoosePatience()
+		}
+		if shop.ChooseClass != nil {
+			client.Class = shop.ChooseClass()
+		}
+		if shop.ChooseTip != nil {
+			client.Tip = shop.ChooseTip(client)
+		}
+		shop.addClient(client)
+	}
 }
 
-func (shop *BarberShop) sendBarberHome(barber string) {
-	color.Cyan("%s is going home.", barber)
-	shop.BarberDoneChan <- true
+// waitForClient waits for gap to pass, and reports whether it did before the shop changed state, which, once the
+// shop is open, it only does when the doors close.
+func (shop *BarberShop) waitForClient(gap time.Duration, changes <-chan StateChange) bool {
+	arrived := make(chan bool, 1)
+	stop := shop.Clock.AfterFunc(gap, func() {
+		shop.Clock.unpark()
+		arrived <- true
+	})
+	shop.Clock.park()
+
+	select {
+	case <-arrived:
+		return true
+	case <-changes:
+		// whoever wakes a parked goroutine has to unpark it, and only one of us is going to
+		if stop() {
+			shop.Clock.unpark()
+		} else {
+			<-arrived
+		}
+		return false
+	}
+}
+
+// newClient returns the next client to walk into the shop, numbered in the order they come in.
+func (shop *BarberShop) newClient() *Client {
+	shop.mutex.Lock()
+	defer shop.mutex.Unlock()
+
+	shop.clients++
+	return newClient(shop.clients)
+}
+
+// waiting returns how many clients are in the waiting room.
+func (shop *BarberShop) waiting() int {
+	shop.mutex.Lock()
+	defer shop.mutex.Unlock()
+	return shop.room.len()
+}
+
+// isOpen reports whether the shop is taking clients.
+func (shop *BarberShop) isOpen() bool {
+	return shop.shopState() == ShopOpen
+}
+
+// stopTakingClients closes the waiting room and wakes up any sleeping or resting barbers, so that they can finish
+// with the clients who are still waiting and go home. It is safe to call more than once, and does nothing unless
+// the shop is open.
+func (shop *BarberShop) stopTakingClients() {
+	shop.mutex.Lock()
+	defer shop.mutex.Unlock()
+
+	if err := shop.setShopState(ShopClosing); err != nil {
+		return
+	}
+
+	color.Cyan("Closing shop for the day.")
+	for len(shop.napping) > 0 {
+		shop.wakeBarber(nil)
+	}
+	for len(shop.resting) > 0 {
+		shop.wakeUp(shop.resting[0])
+	}
+}
+
+// waitForBarbers waits until changes says the shop has closed for the day, once every barber has finished with the
+// clients in the waiting room and gone home, or until late reports how many of them are still at work once the
+// grace period is over.
+func (shop *BarberShop) waitForBarbers(changes <-chan StateChange, late <-chan int) error {
+	// block until every barber is done. On a simulated clock the barbers may well have gone home by the time we
+	// get to look, so being late has to win over being home.
+	for closed := false; !closed; {
+		select {
+		case change, ok := <-changes:
+			if closed = !ok || change.To == ShopClosed; closed {
+				select {
+				case atWork := <-late:
+					return shop.barbersLate(atWork)
+				default:
+				}
+			}
+		case atWork := <-late:
+			return shop.barbersLate(atWork)
+		}
+	}
+
+	color.Green("---------------------------------------------------------------------")
+	color.Green("The barbershop is now closed for the day, and everyone has gone home.")
+	return nil
+}
+
+// barbersLate reports that some barbers were still at work when the grace period was over.
+func (shop *BarberShop) barbersLate(atWork int) error {
+	color.Red("*** %d barbers are still at work after the grace period!", atWork)
+	return fmt.Errorf("%w: %d still at work %v after closing time", ErrGracePeriodExceeded, atWork, shop.GracePeriod)
+}
+
+// addClient seats a newly arrived client in the waiting room. The client never waits for a seat: if every
+// chair is taken, or the shop is closed, the client leaves and is counted as such.
+func (shop *BarberShop) addClient(client *Client) {
+	shop.mutex.Lock()
+	defer shop.mutex.Unlock()
+
+	// print out a message
+	client.Arrived = shop.Clock.Now()
+	color.Green("*** %s arrives!", client)
+	shop.event(EventClientArrived, client, nil)
+
+	if shop.state != ShopOpen {
+		color.Red("The shop is closed, so %s leaves!", client)
+		shop.ClientsAfterHours++
+		shop.event(EventClientTurnedAway, client, nil)
+		return
+	}
+
+	if !shop.canServe(client) {
+		color.Red("Nobody here does a %s, so %s leaves.", client.Service, client)
+		shop.ClientsTurnedAway++
+		shop.ledger.lost(client.Arrived, client.Service, shop.price(client.Service), EventClientTurnedAway)
+		shop.event(EventClientTurnedAway, client, nil)
+		return
+	}
+
+	if shop.room.taken() >= shop.ShopCapacity {
+		color.Red("The waiting room is full, so %s leaves.", client)
+		shop.ClientsTurnedAway++
+		shop.ledger.lost(client.Arrived, client.Service, shop.price(client.Service), EventClientTurnedAway)
+		shop.event(EventClientTurnedAway, client, nil)
+		return
+	}
+
+	client.Seated = client.Arrived
+	shop.room.add(client)
+	color.Yellow("%s takes a seat in the waiting room.", client)
+	if client.Patience > 0 {
+		client.stopWaiting = shop.Clock.AfterFunc(client.Patience, func() {
+			shop.renege(client)
+		})
+	}
+	shop.wakeBarber(client)
+}
+
+// renege is called when a client's patience runs out. If no barber has picked the client up yet, the client
+// gets up and leaves.
+func (shop *BarberShop) renege(client *Client) {
+	shop.mutex.Lock()
+	defer shop.mutex.Unlock()
+
+	if !shop.room.remove(client) {
+		return
+	}
+
+	client.Reneged = shop.Clock.Now()
+	shop.ClientsReneged++
+	shop.ledger.lost(client.Reneged, client.Service, shop.price(client.Service), EventClientReneged)
+	color.Red("%s has waited long enough, and leaves.", client)
+	shop.event(EventClientReneged, client, nil)
+}
+
+// canServe reports whether any of the shop's barbers can serve the client today. A barber whose shift starts
+// only once the doors have closed is not coming in, so does not count. The caller must hold the shop's mutex.
+func (shop *BarberShop) canServe(client *Client) bool {
+	shop.statsMutex.Lock()
+	defer shop.statsMutex.Unlock()
+
+	for _, barber := range shop.barbers {
+		comesIn := shop.closesAt.IsZero() || shop.opened.Add(barber.Schedule.Start).Before(shop.closesAt)
+		if !barber.home && !barber.dismissed && comesIn && barber.canServe(client) {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceDuration is how long the menu says a service takes.
+func (shop *BarberShop) serviceDuration(service Service) time.Duration {
+	if d, ok := shop.Menu[service]; ok {
+		return d
+	}
+	return shop.HairCurDuration
 }
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestShop returns an empty shop that runs on a simulated clock.
+func newTestShop(capacity int, cutDuration time.Duration) *BarberShop {
+	return &BarberShop{
+		ShopCapacity:    capacity,
+		HairCurDuration: cutDuration,
+		BarberDoneChan:  make(chan bool),
+		Clock:           NewSimClock(time.Date(2023, time.January, 2, 9, 0, 0, 0, time.UTC)),
+	}
+}
+
+// runTestDay runs the shop until timeOpen has passed on its clock.
+func runTestDay(t *testing.T, shop *BarberShop, timeOpen time.Duration, arrivals ArrivalProcess) {
+	t.Helper()
+
+	ctx, cancel := context.WithDeadline(context.Background(), shop.Clock.Now().Add(timeOpen))
+	defer cancel()
+
+	if err := shop.Run(ctx, arrivals); err != nil {
+		t.Fatalf("unexpected error running the shop: %v", err)
+	}
+}
+
+func Test_RunOnSimClock(t *testing.T) {
+	runDay := func() Report {
+		shop := newTestShop(3, time.Second)
+		shop.addBarber(Barber{Name: "Frank"})
+		runTestDay(t, shop, 10*time.Second, &FixedArrivals{Interval: 500 * time.Millisecond})
+		return shop.report()
+	}
+
+	started := time.Now()
+	report := runDay()
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("a simulated day should take no real time, but took %v", elapsed)
+	}
+
+	// 19 clients arrive before closing: one every half second, while Frank can only manage one a second
+	if report.ClientsServed+report.ClientsTurnedAway != 19 {
+		t.Errorf("expected 19 clients, but %d were served and %d turned away", report.ClientsServed, report.ClientsTurnedAway)
+	}
+	// Frank cuts hair without a break from the first arrival at 0.5s, and still has a full waiting room at closing
+	if report.ClientsServed != 13 {
+		t.Errorf("expected 13 clients to be served but got %d", report.ClientsServed)
+	}
+	if report.DayLength != 13500*time.Millisecond {
+		t.Errorf("expected Frank to go home at 13.5s, but the day lasted %v", report.DayLength)
+	}
+
+	for ii := 0; ii < 10; ii++ {
+		if again := runDay(); !reflect.DeepEqual(report, again) {
+			t.Fatalf("the same day played out differently:\n%s\n%s", report.Text(), again.Text())
+		}
+	}
+}
+
+func Test_barbersOnlyDoWhatTheyCan(t *testing.T) {
+	shop := newTestShop(5, time.Second)
+	shop.Menu = map[Service]time.Duration{ServiceCut: time.Second, ServiceColor: 4 * time.Second}
+
+	// clients take turns asking for a cut and for a color
+	next := ServiceColor
+	shop.ChooseService = func() Service {
+		if next == ServiceColor {
+			next = ServiceCut
+		} else {
+			next = ServiceColor
+		}
+		return next
+	}
+
+	shop.addBarber(Barber{Name: "Frank", Services: []Service{ServiceCut}})
+	shop.addBarber(Barber{Name: "Susan", Speed: 2, Services: []Service{ServiceColor}})
+	runTestDay(t, shop, 10*time.Second, &FixedArrivals{Interval: time.Second})
+
+	served := shop.clientsServed()
+	if len(served) == 0 {
+		t.Fatal("expected some clients to be served")
+	}
+	for _, client := range served {
+		switch {
+		case client.Service == ServiceCut && client.Barber != "Frank":
+			t.Errorf("%s wanted a cut but was served by %s", client, client.Barber)
+		case client.Service == ServiceColor && client.Barber != "Susan":
+			t.Errorf("%s wanted a color but was served by %s", client, client.Barber)
+		case client.Service == ServiceColor && client.ServiceTime() != 2*time.Second:
+			t.Errorf("Susan works twice as fast, so a color should take 2s, but it took %v", client.ServiceTime())
+		}
+	}
+
+	// nobody in this shop does a shave
+	shop = newTestShop(5, time.Second)
+	shop.ChooseService = func() Service { return ServiceShave }
+	shop.addBarber(Barber{Name: "Frank", Services: []Service{ServiceCut}})
+	runTestDay(t, shop, 5*time.Second, &FixedArrivals{Interval: time.Second})
+	if report := shop.report(); report.ClientsServed != 0 || report.ClientsTurnedAway != 4 {
+		t.Errorf("expected all 4 clients to be turned away, but %d were served and %d turned away",
+			report.ClientsServed, report.ClientsTurnedAway)
+	}
+}
+
+func Test_impatientClientsLeave(t *testing.T) {
+	shop := newTestShop(10, 4*time.Second)
+	shop.ChoosePatience = func() time.Duration { return 2 * time.Second }
+	shop.addBarber(Barber{Name: "Frank"})
+	runTestDay(t, shop, 6*time.Second, &FixedArrivals{Interval: time.Second})
+
+	// Frank takes the client from 1s and is busy until 5s, just in time for the client from 3s, who would have
+	// given up at that very moment. The clients from 2s, 4s and 5s give up while Frank is busy.
+	report := shop.report()
+	if report.ClientsServed != 2 || report.ClientsReneged != 3 {
+		t.Errorf("expected 2 clients served and 3 who gave up, but got %d and %d", report.ClientsServed, report.ClientsReneged)
+	}
+	for _, client := range shop.clientsServed() {
+		if !client.Reneged.IsZero() {
+			t.Errorf("%s gave up waiting, but was served anyway", client)
+		}
+		if client.WaitTime() > client.Patience {
+			t.Errorf("%s only had %v of patience, but waited %v", client, client.Patience, client.WaitTime())
+		}
+	}
+}
+
+func Test_RunGracePeriod(t *testing.T) {
+	shop := newTestShop(3, 10*time.Second)
+	shop.GracePeriod = 5 * time.Second
+	shop.addBarber(Barber{Name: "Frank"})
+
+	// the only client arrives at 1s, and keeps Frank busy until 11s, long after the grace period is over
+	ctx, cancel := context.WithDeadline(context.Background(), shop.Clock.Now().Add(2*time.Second))
+	defer cancel()
+
+	err := shop.Run(ctx, &TraceArrivals{Offsets: []time.Duration{time.Second}})
+	if !errors.Is(err, ErrGracePeriodExceeded) {
+		t.Errorf("expected ErrGracePeriodExceeded but got %v", err)
+	}
+}
+
+func Test_RunCancelled(t *testing.T) {
+	shop := newTestShop(3, time.Second)
+	shop.addBarber(Barber{Name: "Frank"})
+
+	// without a deadline, the shop stays open until the context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := shop.Run(ctx, &FixedArrivals{Interval: time.Second}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if shop.isOpen() {
+		t.Error("expected the shop to be closed")
+	}
+}
+
+func Test_autoscaling(t *testing.T) {
+	shop := newTestShop(4, time.Second)
+	shop.Autoscaling = &AutoscalePolicy{
+		Interval:   500 * time.Millisecond,
+		HighWater:  0.75,
+		LowWater:   0,
+		Sustain:    2,
+		MaxBarbers: 3,
+		Hire:       Barber{Name: "Temp"},
+	}
+	shop.addBarber(Barber{Name: "Frank"})
+
+	// a rush of a client every quarter second for the first five seconds, and then a quiet spell
+	var rush []time.Duration
+	for offset := 250 * time.Millisecond; offset <= 5*time.Second; offset += 250 * time.Millisecond {
+		rush = append(rush, offset)
+	}
+	runTestDay(t, shop, 20*time.Second, &TraceArrivals{Offsets: rush})
+
+	report := shop.report()
+	if report.BarbersHired != 2 {
+		t.Errorf("expected 2 barbers to be called in for the rush, but %d were", report.BarbersHired)
+	}
+	if report.BarbersSentHome != report.BarbersHired {
+		t.Errorf("expected everyone called in to be sent home early, but %d of %d were", report.BarbersSentHome, report.BarbersHired)
+	}
+	if report.BarberMinutes >= 3*report.DayLength.Minutes() {
+		t.Errorf("autoscaling used %.1f barber-minutes, no better than three barbers all day", report.BarberMinutes)
+	}
+	for _, barber := range report.Barbers {
+		if barber.Name == "Frank" && barber.OnDuty != report.DayLength {
+			t.Errorf("Frank should have been at work all day, but only worked %v", barber.OnDuty)
+		}
+	}
+}
+
+func Test_noHiringAfterClosing(t *testing.T) {
+	shop := newTestShop(3, time.Second)
+	if !shop.addBarber(Barber{Name: "Frank"}) {
+		t.Fatal("expected Frank to be taken on before the shop opens")
+	}
+	runTestDay(t, shop, 2*time.Second, &FixedArrivals{Interval: 500 * time.Millisecond})
+
+	// once the shop has closed, and stopped counting barbers, a barber called in late would never be counted home
+	if shop.addBarber(Barber{Name: "Latecomer"}) {
+		t.Error("expected nobody to be taken on once the shop has closed")
+	}
+	shop.mutex.Lock()
+	defer shop.mutex.Unlock()
+	if shop.NumberOfBarbers != 1 || shop.atWork != 0 {
+		t.Errorf("expected one barber, who has gone home, but there are %d, with %d at work", shop.NumberOfBarbers, shop.atWork)
+	}
+}
+
+func Test_barberSchedules(t *testing.T) {
+	shop := newTestShop(5, time.Second)
+	opened := shop.Clock.Now()
+	shop.addBarber(Barber{Name: "Frank", Schedule: Schedule{
+		Start:  2 * time.Second,
+		End:    8 * time.Second,
+		Breaks: []Break{{Start: 4 * time.Second, Length: 2 * time.Second}},
+	}})
+	shop.addBarber(Barber{Name: "Bob"})
+	runTestDay(t, shop, 10*time.Second, &FixedArrivals{Interval: 500 * time.Millisecond})
+
+	// Frank may finish a haircut after the break starts or the shift ends, but never starts one then
+	for _, client := range shop.clientsServed() {
+		if client.Barber != "Frank" {
+			continue
+		}
+		started := client.CutStarted.Sub(opened)
+		if started < 2*time.Second || (started >= 4*time.Second && started < 6*time.Second) || started >= 8*time.Second {
+			t.Errorf("Frank started on %s at %v, when Frank was not working", client, started)
+		}
+	}
+	for _, barber := range shop.report().Barbers {
+		if barber.Name == "Frank" && barber.Breaks != 2*time.Second {
+			t.Errorf("expected Frank to take a 2s break, but Frank took %v", barber.Breaks)
+		}
+	}
+
+	// the last barber cannot leave while clients are waiting, even if the shift is over
+	shop = newTestShop(5, 2*time.Second)
+	shop.addBarber(Barber{Name: "Frank", Schedule: Schedule{End: 3 * time.Second}})
+	runTestDay(t, shop, 6*time.Second, &FixedArrivals{Interval: time.Second})
+	if report := shop.report(); report.ClientsServed != 5 {
+		t.Errorf("expected Frank to stay until all 5 clients were served, but only %d were", report.ClientsServed)
+	}
+}
+
+func Test_shiftAfterClosing(t *testing.T) {
+	// Kelly is the only one who shaves, but is not due in until after the shop closes
+	shop := newTestShop(3, time.Second)
+	shop.addBarber(Barber{Name: "Frank", Services: []Service{ServiceCut}})
+	shop.addBarber(Barber{Name: "Kelly", Services: []Service{ServiceShave}, Schedule: Schedule{Start: 8 * time.Second}})
+	shop.ChooseService = func() Service { return ServiceShave }
+	runTestDay(t, shop, 5*time.Second, &TraceArrivals{Offsets: []time.Duration{2 * time.Second}})
+
+	// so the client who wants a shave is turned away, rather than left waiting for nobody
+	report := shop.report()
+	if report.ClientsTurnedAway != 1 || report.ClientsServed != 0 {
+		t.Errorf("expected the client to be turned away, but %d were served and %d turned away",
+			report.ClientsServed, report.ClientsTurnedAway)
+	}
+	if waiting := shop.waiting(); waiting != 0 {
+		t.Errorf("expected nobody to be left waiting, but %d are", waiting)
+	}
+}
+
+func Test_barberPanics(t *testing.T) {
+	// a barber panics just as the third haircut of the day starts, and again at the sixth
+	shop := newTestShop(3, time.Second)
+	var mutex sync.Mutex
+	cuts := 0
+	shop.ChooseServiceTime = func(d time.Duration) time.Duration {
+		mutex.Lock()
+		cuts++
+		n := cuts
+		mutex.Unlock()
+		if n == 3 || n == 6 {
+			panic("scissors broke")
+		}
+		return d
+	}
+	shop.addBarber(Barber{Name: "Frank"})
+	shop.addBarber(Barber{Name: "Bob"})
+	runTestDay(t, shop, 10*time.Second, &FixedArrivals{Interval: 500 * time.Millisecond})
+
+	report := shop.report()
+	panics := 0
+	for _, barber := range report.Barbers {
+		panics += barber.Panics
+	}
+	if panics != 2 {
+		t.Errorf("expected the barbers to panic twice, but they panicked %d times", panics)
+	}
+
+	// nobody is lost: every client who got a seat is served exactly once, even those whose haircuts went wrong
+	seen := make(map[int]bool)
+	for _, client := range shop.clientsServed() {
+		if seen[client.ID] {
+			t.Errorf("%s was served twice", client)
+		}
+		seen[client.ID] = true
+	}
+	if arrived := report.ClientsServed + report.ClientsTurnedAway; arrived != 19 {
+		t.Errorf("expected all 19 clients to be served or turned away, but %d were", arrived)
+	}
+	if revenue := shop.ledger.revenue(); revenue.Clients != report.ClientsServed {
+		t.Errorf("expected %d clients to pay, but %d did", report.ClientsServed, revenue.Clients)
+	}
+}
+
+func Test_barberPanicsWithFullRoom(t *testing.T) {
+	// Frank panics a while into the first haircut of the day, by which time someone has taken the only seat
+	shop := newTestShop(1, time.Second)
+	first := true
+	shop.ChooseServiceTime = func(d time.Duration) time.Duration {
+		if first {
+			first = false
+			shop.Clock.Sleep(300 * time.Millisecond)
+			panic("scissors broke")
+		}
+		return d
+	}
+	shop.addBarber(Barber{Name: "Frank"})
+	runTestDay(t, shop, 3*time.Second, &TraceArrivals{Offsets: []time.Duration{
+		0, 100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond,
+	}})
+
+	// the first client goes back to the front of the line without taking the seat, and the last client, like the
+	// third, finds it taken
+	shop.mutex.Lock()
+	peak := shop.room.peak
+	shop.mutex.Unlock()
+	if peak > shop.ShopCapacity {
+		t.Errorf("expected no more than %d seats to be taken, but %d were", shop.ShopCapacity, peak)
+	}
+	var served []string
+	for _, client := range shop.clientsServed() {
+		served = append(served, client.Name)
+	}
+	if report := shop.report(); len(served) != 2 || served[0] != "Client #1" || served[1] != "Client #2" ||
+		report.ClientsTurnedAway != 2 {
+		t.Errorf("expected the first two clients to be served, in order, and the other two to be turned away, but %v "+
+			"were served and %d turned away", served, report.ClientsTurnedAway)
+	}
+}
+
+func Test_barberPanicBackoff(t *testing.T) {
+	// Frank panics on his first three haircuts, one after another, and then not again until the twelfth
+	shop := newTestShop(10, time.Second)
+	shop.RestartBackoff = time.Second
+	var log bytes.Buffer
+	shop.EventLog = &log
+	var mutex sync.Mutex
+	cuts := 0
+	shop.ChooseServiceTime = func(d time.Duration) time.Duration {
+		mutex.Lock()
+		cuts++
+		n := cuts
+		mutex.Unlock()
+		if n <= 3 || n == 12 {
+			panic("scissors broke")
+		}
+		return d
+	}
+	shop.addBarber(Barber{Name: "Frank"})
+	runTestDay(t, shop, 30*time.Second, &FixedArrivals{Interval: 500 * time.Millisecond})
+
+	// Frank is away for 1s, 2s and 4s after the first three panics, but after more than eight seconds of good work
+	// the last panic keeps Frank away for just 1s
+	var away []time.Duration
+	var panicked time.Time
+	for _, event := range readEvents(t, &log) {
+		switch {
+		case event.Kind == EventBarberPanicked:
+			panicked = event.Time
+		case event.Kind == EventCutStarted && !panicked.IsZero():
+			away = append(away, event.Time.Sub(panicked))
+			panicked = time.Time{}
+		}
+	}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, time.Second}
+	if len(away) != len(expected) {
+		t.Fatalf("expected Frank to be away %v after panicking, but he was away %v", expected, away)
+	}
+	for ii := range expected {
+		if away[ii] != expected[ii] {
+			t.Errorf("expected Frank to be away %v after panicking, but he was away %v", expected, away)
+			break
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Router decides which of a chain's shops each arriving client is sent to.
+type Router interface {
+	Route(shops []*BarberShop, client *Client) int
+}
+
+// RandomRouter sends each client to a shop picked at random.
+type RandomRouter struct {
+	Rng *rand.Rand
+}
+
+func (r *RandomRouter) Route(shops []*BarberShop, client *Client) int {
+	return r.Rng.Intn(len(shops))
+}
+
+// RoundRobinRouter sends clients to each shop in turn.
+type RoundRobinRouter struct {
+	next int
+}
+
+func (r *RoundRobinRouter) Route(shops []*BarberShop, client *Client) int {
+	shop := r.next % len(shops)
+	r.next = shop + 1
+	return shop
+}
+
+// ShortestQueueRouter sends each client to the shop with the fewest clients waiting, or the first of them if
+// several are as short.
+type ShortestQueueRouter struct{}
+
+func (r ShortestQueueRouter) Route(shops []*BarberShop, client *Client) int {
+	best, shortest := 0, shops[0].waiting()
+	for ii, shop := range shops[1:] {
+		if waiting := shop.waiting(); waiting < shortest {
+			best, shortest = ii+1, waiting
+		}
+	}
+	return best
+}
+
+// PowerOfTwoRouter picks two shops at random, and sends each client to whichever of them has fewer clients
+// waiting. It does nearly as well as looking at every shop, while only ever looking at two.
+type PowerOfTwoRouter struct {
+	Rng *rand.Rand
+}
+
+func (r *PowerOfTwoRouter) Route(shops []*BarberShop, client *Client) int {
+	if len(shops) < 2 {
+		return 0
+	}
+	first := r.Rng.Intn(len(shops))
+	second := r.Rng.Intn(len(shops) - 1)
+	if second >= first {
+		second++
+	}
+	if shops[second].waiting() < shops[first].waiting() {
+		return second
+	}
+	return first
+}
+
+// newRouter returns the routing strategy called kind: "random", "round-robin", "shortest-queue" or
+// "power-of-two". The random strategies draw from rng.
+func newRouter(kind string, rng *rand.Rand) (Router, error) {
+	switch kind {
+	case "random":
+		return &RandomRouter{Rng: rng}, nil
+	case "round-robin":
+		return &RoundRobinRouter{}, nil
+	case "shortest-queue":
+		return ShortestQueueRouter{}, nil
+	case "power-of-two":
+		return &PowerOfTwoRouter{Rng: rng}, nil
+	}
+	return nil, fmt.Errorf("unknown routing strategy %q", kind)
+}
+
+// Chain is several barbershops that share their clients: every client arrives at the chain, and its router
+// decides which shop the client goes to.
+type Chain struct {
+	Shops          []*BarberShop // every shop must go by Clock
+	Router         Router
+	Clock          Clock
+	ChooseService  func() Service       // picks what each new client asks for; nil means everyone wants a cut
+	ChoosePatience func() time.Duration // picks how patient each new client is; nil means everyone waits forever
+	ChooseClass    func() ClientClass   // picks whether each new client is a VIP; nil means nobody is
+}
+
+// Run opens every shop in the chain and keeps them open until ctx's deadline, as each shop's Run does, sending
+// in clients as the arrival process dictates and routing each of them to a shop. It returns once every barber in
+// every shop has gone home, with the first error any of the shops ran into.
+func (chain *Chain) Run(ctx context.Context, arrivals ArrivalProcess) error {
+	// open every shop at the same moment, before any clients arrive
+	opened := make(chan bool)
+	waits := make([]func() error, len(chain.Shops))
+	errs := make([]error, len(chain.Shops))
+	chain.Clock.Go(func() {
+		for ii, shop := range chain.Shops {
+			waits[ii], errs[ii] = shop.start(ctx, &TraceArrivals{})
+		}
+		chain.Clock.Go(func() {
+			chain.sendInClients(arrivals)
+		})
+		close(opened)
+	})
+	<-opened
+
+	for ii, wait := range waits {
+		if wait != nil {
+			errs[ii] = wait()
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendInClients sends clients to the chain's shops as the arrival process dictates, until every shop has
+// closed or no more clients are coming.
+func (chain *Chain) sendInClients(arrivals ArrivalProcess) {
+	for id := 1; ; id++ {
+		gap, ok := arrivals.Next()
+		if !ok {
+			return
+		}
+		chain.Clock.Sleep(gap)
+
+		anyOpen := false
+		for _, shop := range chain.Shops {
+			anyOpen = anyOpen || shop.isOpen()
+		}
+		if !anyOpen {
+			return
+		}
+
+		client := newClient(id)
+		if chain.ChooseService != nil {
+			client.Service = chain.ChooseService()
+		}
+		if chain.ChoosePatience != nil {
+			client.Patience = chain.ChoosePatience()
+		}
+		if chain.ChooseClass != nil {
+			client.Class = chain.ChooseClass()
+		}
+		chain.Shops[chain.Router.Route(chain.Shops, client)].addClient(client)
+	}
+}
+
+// ChainReport is the end-of-day summary of a chain of barbershops, under one routing strategy.
+type ChainReport struct {
+	Router            string
+	Shops             []Report
+	ClientsServed     int
+	ClientsTurnedAway int
+	ClientsReneged    int
+	TurnedAwayRate    float64 // the fraction of clients who arrived while the shops were open and were turned away
+	AverageWait       time.Duration
+	P95Wait           time.Duration
+}
+
+// report builds the end-of-day summary for the chain. It should only be called after Run has returned.
+func (chain *Chain) report(router string) ChainReport {
+	r := ChainReport{Router: router}
+	var served []*Client
+	for _, shop := range chain.Shops {
+		report := shop.report()
+		r.Shops = append(r.Shops, report)
+		r.ClientsTurnedAway += report.ClientsTurnedAway
+		r.ClientsReneged += report.ClientsReneged
+		served = append(served, shop.clientsServed()...)
+	}
+	r.ClientsServed = len(served)
+
+	if arrived := r.ClientsServed + r.ClientsTurnedAway + r.ClientsReneged; arrived > 0 {
+		r.TurnedAwayRate = float64(r.ClientsTurnedAway) / float64(arrived)
+	}
+	waits := waitStats(served)
+	r.AverageWait, r.P95Wait = waits.Average, waits.P95
+	return r
+}
+
+// compareRouters runs the same day at a chain of shops once for each routing strategy in kinds, and reports how
+// the chain did under each of them. newChain builds a fresh chain, with its clients' arrivals, for each run; so that
+// the strategies can be compared fairly, it should give every run the same arrivals. The chain is open for
+// timeOpen, as measured by its clock. The random strategies draw from a source of their own, seeded with
+// routingSeed, which should not be the seed the arrivals and services are drawn with: otherwise the strategies'
+// choices would follow the very numbers that decided the load, rather than being independent of it.
+func compareRouters(kinds []string, timeOpen time.Duration, routingSeed int64,
+	newChain func(router Router) (*Chain, ArrivalProcess, error)) ([]ChainReport, error) {
+	var reports []ChainReport
+	for _, kind := range kinds {
+		router, err := newRouter(kind, rand.New(rand.NewSource(routingSeed)))
+		if err != nil {
+			return nil, err
+		}
+		chain, arrivals, err := newChain(router)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithDeadline(context.Background(), chain.Clock.Now().Add(timeOpen))
+		err = chain.Run(ctx, arrivals)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", kind, err)
+		}
+		reports = append(reports, chain.report(kind))
+	}
+	return reports, nil
+}
+
+// compareText returns a table comparing how a chain did under each routing strategy.
+func compareText(reports []ChainReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-16s %8s %12s %8s %10s %10s\n", "Router", "Served", "Turned away", "Gave up", "Avg wait", "P95 wait")
+	for _, r := range reports {
+		fmt.Fprintf(&b, "%-16s %8d %6d %4.1f%% %8d %10v %10v\n", r.Router, r.ClientsServed, r.ClientsTurnedAway,
+			r.TurnedAwayRate*100, r.ClientsReneged, r.AverageWait.Round(time.Millisecond), r.P95Wait.Round(time.Millisecond))
+	}
+	return b.String()
+}
+
+// printComparison prints a comparison of routing strategies, the same way as the shop's own report.
+func printComparison(reports []ChainReport) {
+	color.Green("---------------------------------------------------------------------")
+	for _, line := range strings.Split(strings.TrimSpace(compareText(reports)), "\n") {
+		color.Yellow(line)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func Test_routers(t *testing.T) {
+	// three shops, with 2, 0 and 1 clients waiting
+	clock := NewSimClock(time.Date(2023, 1, 2, 9, 0, 0, 0, time.UTC))
+	var shops []*BarberShop
+	for _, waiting := range []int{2, 0, 1} {
+		shop := &BarberShop{ShopCapacity: 5, Clock: clock}
+		for ii := 0; ii < waiting; ii++ {
+			shop.room.add(newClient(ii + 1))
+		}
+		shops = append(shops, shop)
+	}
+	client := newClient(10)
+
+	if got := (ShortestQueueRouter{}).Route(shops, client); got != 1 {
+		t.Errorf("expected the shortest queue to be at shop 1, but got %d", got)
+	}
+
+	roundRobin := &RoundRobinRouter{}
+	for _, want := range []int{0, 1, 2, 0, 1} {
+		if got := roundRobin.Route(shops, client); got != want {
+			t.Errorf("expected round-robin to pick shop %d, but got %d", want, got)
+		}
+	}
+
+	// whichever two shops power-of-two picks, it never picks the longest queue of all
+	powerOfTwo := &PowerOfTwoRouter{Rng: rand.New(rand.NewSource(1))}
+	random := &RandomRouter{Rng: rand.New(rand.NewSource(1))}
+	for ii := 0; ii < 100; ii++ {
+		if got := powerOfTwo.Route(shops, client); got == 0 {
+			t.Fatal("expected power-of-two never to pick the longest queue")
+		}
+		if got := random.Route(shops, client); got < 0 || got >= len(shops) {
+			t.Fatalf("random picked shop %d, which does not exist", got)
+		}
+	}
+}
+
+func Test_compareRouters(t *testing.T) {
+	newChain := func(router Router) (*Chain, ArrivalProcess, error) {
+		clock := NewSimClock(time.Date(2023, 1, 2, 9, 0, 0, 0, time.UTC))
+		chain := &Chain{Router: router, Clock: clock}
+		for ii := 0; ii < 2; ii++ {
+			shop := newTestShop(2, time.Second)
+			shop.Clock = clock
+			shop.addBarber(Barber{Name: "Frank"})
+			chain.Shops = append(chain.Shops, shop)
+		}
+		return chain, &FixedArrivals{Interval: 500 * time.Millisecond}, nil
+	}
+
+	reports, err := compareRouters([]string{"round-robin", "shortest-queue", "random"}, 10*time.Second, 1, newChain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 3 {
+		t.Fatalf("expected a report for each of 3 routers, but got %d", len(reports))
+	}
+
+	// a client every second at each shop is just what one barber can manage, so taking turns works perfectly,
+	// and so does looking for the shortest queue
+	for _, r := range reports[:2] {
+		if r.ClientsTurnedAway != 0 || r.ClientsServed != 19 {
+			t.Errorf("%s: expected all 19 clients to be served, but %d were and %d were turned away",
+				r.Router, r.ClientsServed, r.ClientsTurnedAway)
+		}
+	}
+	if served := [2]int{reports[0].Shops[0].ClientsServed, reports[0].Shops[1].ClientsServed}; served != [2]int{10, 9} {
+		t.Errorf("expected round-robin to share the clients out evenly, but the shops served %v", served)
+	}
+
+	if _, err := compareRouters([]string{"telepathy"}, time.Second, 1, newChain); err == nil {
+		t.Error("expected an unknown routing strategy to be an error")
+	}
+}
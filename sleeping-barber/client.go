@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Client describes one visit to the barbershop, from the moment the client walks in until the haircut is done.
+// The zero value of any of the times means that the client never got that far.
+type Client struct {
+	ID          int
+	Name        string
+	Service     Service
+	Class       ClientClass
+	Patience    time.Duration // how long the client will sit in the waiting room before giving up; zero means forever
+	Arrived     time.Time
+	Seated      time.Time
+	Reneged     time.Time // when the client gave up waiting and left
+	CutStarted  time.Time
+	CutFinished time.Time
+	Barber      string
+	Appointment time.Time // when the client's appointment was; zero for clients who walked in
+	Price       Cents     // what the client paid for the service, once served
+	Tip         Cents     // what the client tips, if served
+
+	stopWaiting   func() bool // cancels the client's patience running out, once a barber has picked the client up
+	delaysWalkIns bool        // the client has an appointment, and is served while walk-ins wait
+}
+
+// ClientClass decides who goes first when several clients are waiting.
+type ClientClass string
+
+const (
+	ClassRegular ClientClass = "regular"
+	ClassVIP     ClientClass = "vip"
+)
+
+// newClient returns a client that is about to walk into the shop.
+func newClient(id int) *Client {
+	return &Client{
+		ID:      id,
+		Name:    fmt.Sprintf("Client #%d", id),
+		Service: ServiceCut,
+		Class:   ClassRegular,
+	}
+}
+
+// String lets a client be printed by name in the shop's log messages.
+func (c *Client) String() string {
+	return c.Name
+}
+
+// WaitTime is how long the client sat in the waiting room, either until a barber started the haircut or until
+// the client gave up.
+func (c *Client) WaitTime() time.Duration {
+	switch {
+	case c.Seated.IsZero():
+		return 0
+	case !c.Reneged.IsZero():
+		return c.Reneged.Sub(c.Seated)
+	case !c.CutStarted.IsZero():
+		return c.CutStarted.Sub(c.Seated)
+	}
+	return 0
+}
+
+// ServiceTime is how long the haircut itself took.
+func (c *Client) ServiceTime() time.Duration {
+	if c.CutStarted.IsZero() || c.CutFinished.IsZero() {
+		return 0
+	}
+	return c.CutFinished.Sub(c.CutStarted)
+}
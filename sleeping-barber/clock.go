@@ -0,0 +1,202 @@
+package main
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Clock is where the barbershop gets the time from, and how its goroutines wait for time to pass. The
+// barbershop never calls time.Now or time.Sleep directly, so that a whole day can be run on a SimClock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Sleep pauses the calling goroutine for d.
+	Sleep(d time.Duration)
+	// AfterFunc calls f in its own goroutine once d has passed. Calling stop before then cancels the call,
+	// and reports whether it was cancelled.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+	// Go runs f in a new goroutine that takes part in the simulation.
+	Go(f func())
+
+	// park is called by a goroutine started with Go just before it blocks waiting for another one,
+	// and unpark is called by whoever wakes it up again, just before doing so.
+	park()
+	unpark()
+}
+
+// RealClock is the wall clock.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time        { return time.Now() }
+func (RealClock) Sleep(d time.Duration) { time.Sleep(d) }
+func (RealClock) Go(f func())           { go f() }
+func (RealClock) park()                 {}
+func (RealClock) unpark()               {}
+
+func (RealClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// SimClock is a simulated clock. Time stands still while any goroutine started with Go is doing something,
+// and jumps straight to the next timer once every one of them is asleep or parked. A ten-second day therefore
+// takes no longer than the work done in it, and since only one timer fires at a time, a day always plays out
+// the same way.
+//
+// Time only moves forward when a goroutine started with Go sleeps, parks or returns, so a SimClock must not
+// be shared with goroutines that wait on it without having been started by it.
+type SimClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	running int // goroutines started with Go that are neither sleeping nor parked
+	timers  simTimers
+	seq     int // breaks ties between timers due at the same moment, in the order they were set
+}
+
+// NewSimClock returns a simulated clock that starts at the given time.
+func NewSimClock(start time.Time) *SimClock {
+	return &SimClock{now: start}
+}
+
+func (c *SimClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *SimClock) Sleep(d time.Duration) {
+	wake := make(chan bool)
+
+	c.mutex.Lock()
+	c.addTimer(d, nil, wake)
+	c.running--
+	c.advance()
+	c.mutex.Unlock()
+
+	<-wake
+}
+
+func (c *SimClock) AfterFunc(d time.Duration, f func()) func() bool {
+	c.mutex.Lock()
+	t := c.addTimer(d, f, nil)
+	c.mutex.Unlock()
+
+	return func() bool {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		if t.index < 0 {
+			return false
+		}
+		heap.Remove(&c.timers, t.index)
+		return true
+	}
+}
+
+func (c *SimClock) Go(f func()) {
+	c.mutex.Lock()
+	c.running++
+	c.mutex.Unlock()
+
+	go func() {
+		defer c.done()
+		f()
+	}()
+}
+
+func (c *SimClock) park() {
+	c.mutex.Lock()
+	c.running--
+	c.advance()
+	c.mutex.Unlock()
+}
+
+func (c *SimClock) unpark() {
+	c.mutex.Lock()
+	c.running++
+	c.mutex.Unlock()
+}
+
+// done is called when a goroutine started with Go returns.
+func (c *SimClock) done() {
+	c.mutex.Lock()
+	c.running--
+	c.advance()
+	c.mutex.Unlock()
+}
+
+// addTimer schedules either a call to f or the closing of wake. The caller must hold the mutex.
+func (c *SimClock) addTimer(d time.Duration, f func(), wake chan bool) *simTimer {
+	if d < 0 {
+		d = 0
+	}
+	c.seq++
+	t := &simTimer{when: c.now.Add(d), seq: c.seq, f: f, wake: wake}
+	heap.Push(&c.timers, t)
+	return t
+}
+
+// advance fires the next timer if nothing else is going on. The woken goroutine counts as running, so
+// nothing else fires until it, and anything it wakes up in turn, has gone back to waiting. The caller must
+// hold the mutex.
+func (c *SimClock) advance() {
+	if c.running > 0 || len(c.timers) == 0 {
+		return
+	}
+
+	t := heap.Pop(&c.timers).(*simTimer)
+	if t.when.After(c.now) {
+		c.now = t.when
+	}
+
+	c.running++
+	if t.f != nil {
+		go func() {
+			defer c.done()
+			t.f()
+		}()
+	} else {
+		close(t.wake)
+	}
+}
+
+// simTimer is a pending wake-up on a SimClock.
+type simTimer struct {
+	when  time.Time
+	seq   int
+	f     func()
+	wake  chan bool
+	index int // position in the heap, or -1 once the timer has fired or been stopped
+}
+
+// simTimers is a heap of timers, soonest first.
+type simTimers []*simTimer
+
+func (h simTimers) Len() int { return len(h) }
+
+func (h simTimers) Less(i, j int) bool {
+	if h[i].when.Equal(h[j].when) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].when.Before(h[j].when)
+}
+
+func (h simTimers) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *simTimers) Push(x any) {
+	t := x.(*simTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *simTimers) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is everything that makes one day at the shop different from another. It can be read from a JSON or
+// YAML scenario file, and overridden from the command line.
+type Config struct {
+	Seed             int64                `json:"seed"` // zero means a different day every time
+	Capacity         int                  `json:"capacity"`
+	Arrivals         string               `json:"arrivals"`     // "fixed", "uniform", "poisson", "bursty" or "trace"
+	ArrivalRate      Duration             `json:"arrival_rate"` // the average time between clients
+	ArrivalTrace     string               `json:"arrival_trace"`
+	CutDuration      Duration             `json:"cut_duration"`
+	OpenFor          Duration             `json:"open_for"`
+	GracePeriod      Duration             `json:"grace_period"`
+	Patience         Duration             `json:"patience"` // zero means clients wait forever
+	VIPShare         float64              `json:"vip_share"`
+	AgingThreshold   Duration             `json:"aging_threshold"`
+	Autoscaling      bool                 `json:"autoscaling"`
+	MaxBarbers       int                  `json:"max_barbers"`
+	Barbers          []BarberConfig       `json:"barbers"`
+	Menu             map[Service]Duration `json:"menu"` // empty means a shave takes half as long as a cut, and color twice as long
+	ServiceMix       []ServiceWeight      `json:"service_mix"`
+	Prices           map[Service]float64  `json:"prices"`
+	TipRate          float64              `json:"tip_rate"` // the average tip, as a fraction of the price
+	Appointments     []AppointmentConfig  `json:"appointments"`
+	AppointmentGrace Duration             `json:"appointment_grace"`
+	Report           string               `json:"report"` // "text" or "json"
+	EventLog         string               `json:"event_log"`
+	ChainSize        int                  `json:"chain_size"`
+	Routers          []string             `json:"routers"`
+	Analysis         int                  `json:"analysis"` // how many days to check against queueing theory; zero means none
+	Sweep            SweepConfig          `json:"sweep"`
+	API              string               `json:"api"`
+	Dashboard        bool                 `json:"dashboard"` // only when the output is a terminal
+}
+
+// BarberConfig is a barber, as written in a scenario file.
+type BarberConfig struct {
+	Name     string        `json:"name"`
+	Speed    float64       `json:"speed"`
+	Services []Service     `json:"services"`
+	Start    Duration      `json:"start"`
+	End      Duration      `json:"end"`
+	Breaks   []BreakConfig `json:"breaks"`
+}
+
+// BreakConfig is a barber's break, as written in a scenario file.
+type BreakConfig struct {
+	Start  Duration `json:"start"`
+	Length Duration `json:"length"`
+}
+
+// AppointmentConfig is an appointment, as written in a scenario file.
+type AppointmentConfig struct {
+	Name    string   `json:"name"`
+	Barber  string   `json:"barber"`
+	At      Duration `json:"at"`
+	Service Service  `json:"service"`
+	Late    Duration `json:"late"`
+	NoShow  bool     `json:"no_show"`
+}
+
+// SweepConfig is a grid of parameters to run the shop over, writing how each run went as a row of CSV. Whatever
+// the sweep leaves out is the same as the rest of the configuration, except for the barbers, who go from one up to
+// max_barbers.
+type SweepConfig struct {
+	Output       string     `json:"output"` // the CSV file to write, or "-" for standard output; empty means no sweep
+	Barbers      []int      `json:"barbers"`
+	Capacities   []int      `json:"capacities"`
+	ArrivalRates []Duration `json:"arrival_rates"`
+	CutDurations []Duration `json:"cut_durations"`
+	Seeds        int        `json:"seeds"` // how many times to run each combination, each time with a different seed
+}
+
+// Duration is a time.Duration that is written in scenario files the way Go writes durations, such as "1.5s", or
+// as a number of seconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+		return nil
+	}
+
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return fmt.Errorf("a duration must be a string such as \"1.5s\", or a number of seconds, not %s", data)
+	}
+	*d = Duration(seconds * float64(time.Second))
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// defaultConfig is the day described by the package's variables. Its slices and maps are copies, so that reading a
+// scenario file over it leaves the package's variables alone.
+func defaultConfig() Config {
+	cfg := Config{
+		Capacity:         seatingCapacity,
+		Arrivals:         arrivalKind,
+		ArrivalRate:      Duration(time.Duration(arrivalRate) * time.Millisecond),
+		ArrivalTrace:     arrivalTrace,
+		CutDuration:      Duration(cutDuration),
+		OpenFor:          Duration(timeOpen),
+		GracePeriod:      Duration(gracePeriod),
+		Patience:         Duration(clientPatience),
+		VIPShare:         vipShare,
+		AgingThreshold:   Duration(agingThreshold),
+		Autoscaling:      autoscaling,
+		MaxBarbers:       maxBarbers,
+		ServiceMix:       append([]ServiceWeight(nil), serviceMix...),
+		Prices:           make(map[Service]float64),
+		TipRate:          tipRate,
+		AppointmentGrace: Duration(appointmentGrace),
+		Report:           reportFormat,
+		EventLog:         eventLog,
+		ChainSize:        chainSize,
+		Routers:          append([]string(nil), routers...),
+		Analysis:         analysisRuns,
+		Sweep:            SweepConfig{Seeds: sweepSeeds},
+		API:              apiAddr,
+		Dashboard:        dashboard,
+	}
+	for service, price := range prices {
+		cfg.Prices[service] = price
+	}
+	for _, barber := range barbers {
+		bc := BarberConfig{Name: barber.Name, Speed: barber.Speed, Services: append([]Service(nil), barber.Services...),
+			Start: Duration(barber.Schedule.Start), End: Duration(barber.Schedule.End)}
+		for _, b := range barber.Schedule.Breaks {
+			bc.Breaks = append(bc.Breaks, BreakConfig{Start: Duration(b.Start), Length: Duration(b.Length)})
+		}
+		cfg.Barbers = append(cfg.Barbers, bc)
+	}
+	for _, a := range appointments {
+		cfg.Appointments = append(cfg.Appointments, AppointmentConfig{Name: a.Name, Barber: a.Barber, At: Duration(a.At),
+			Service: a.Service, Late: Duration(a.Late), NoShow: a.NoShow})
+	}
+	return cfg
+}
+
+// loadConfig starts from defaults, reads the scenario file named by the -config flag in args if there is one, and
+// then applies the rest of the flags in args on top. The result is validated before it is returned.
+func loadConfig(args []string, defaults Config) (Config, error) {
+	// find the scenario file first, so that the other flags can override what it says
+	var scratch Config
+	var path string
+	if err := configFlags(&scratch, &path).Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg := defaults
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := configFlags(&cfg, &path).Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, cfg.validate()
+}
+
+// configFlags returns the command-line flags, which set the fields of cfg that they are named after.
+func configFlags(cfg *Config, path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("sleeping-barber", flag.ContinueOnError)
+	fs.StringVar(path, "config", "", "a JSON or YAML scenario `file` to run")
+	fs.Int64Var(&cfg.Seed, "seed", cfg.Seed, "seed for the random number generator; zero means a different day every time")
+	fs.IntVar(&cfg.Capacity, "capacity", cfg.Capacity, "seats in the waiting room")
+	fs.StringVar(&cfg.Arrivals, "arrivals", cfg.Arrivals, "how clients arrive: fixed, uniform, poisson, bursty or trace")
+	fs.DurationVar((*time.Duration)(&cfg.ArrivalRate), "arrival-rate", time.Duration(cfg.ArrivalRate), "the average time between clients")
+	fs.StringVar(&cfg.ArrivalTrace, "arrival-trace", cfg.ArrivalTrace, "the CSV `file` of arrival times replayed by the trace arrivals")
+	fs.DurationVar((*time.Duration)(&cfg.CutDuration), "cut", time.Duration(cfg.CutDuration), "how long a haircut takes")
+	fs.DurationVar((*time.Duration)(&cfg.OpenFor), "open-for", time.Duration(cfg.OpenFor), "how long the shop is open")
+	fs.DurationVar((*time.Duration)(&cfg.GracePeriod), "grace", time.Duration(cfg.GracePeriod), "how long the barbers may take to finish up after closing time")
+	fs.DurationVar((*time.Duration)(&cfg.Patience), "patience", time.Duration(cfg.Patience), "how long clients are willing to wait, on average")
+	fs.Float64Var(&cfg.VIPShare, "vip-share", cfg.VIPShare, "the fraction of clients who are VIPs")
+	fs.DurationVar((*time.Duration)(&cfg.AgingThreshold), "aging", time.Duration(cfg.AgingThreshold), "how long a regular client waits before being served like a VIP")
+	fs.BoolVar(&cfg.Autoscaling, "autoscaling", cfg.Autoscaling, "call in extra barbers when the waiting room fills up")
+	fs.IntVar(&cfg.MaxBarbers, "max-barbers", cfg.MaxBarbers, "the most barbers who may be at work at once, when autoscaling")
+	fs.Func("barbers", "comma-separated `names` of the barbers, who all do everything at the usual speed; appointments with anyone else are cancelled", func(names string) error {
+		cfg.Barbers = nil
+		working := make(map[string]bool)
+		for _, name := range strings.Split(names, ",") {
+			cfg.Barbers = append(cfg.Barbers, BarberConfig{Name: strings.TrimSpace(name), Speed: 1})
+			working[strings.TrimSpace(name)] = true
+		}
+		var kept []AppointmentConfig
+		for _, a := range cfg.Appointments {
+			if working[a.Barber] {
+				kept = append(kept, a)
+			}
+		}
+		cfg.Appointments = kept
+		return nil
+	})
+	fs.Float64Var(&cfg.TipRate, "tip-rate", cfg.TipRate, "the average tip, as a fraction of the price")
+	fs.DurationVar((*time.Duration)(&cfg.AppointmentGrace), "appointment-grace", time.Duration(cfg.AppointmentGrace), "how late a client may be for an appointment")
+	fs.StringVar(&cfg.Report, "report", cfg.Report, "how the end-of-day report is printed: text or json")
+	fs.StringVar(&cfg.EventLog, "event-log", cfg.EventLog, "the `file` every event is written to as JSON lines")
+	fs.IntVar(&cfg.ChainSize, "chain", cfg.ChainSize, "how many shops to compare client routing strategies over")
+	fs.IntVar(&cfg.Analysis, "analyze", cfg.Analysis, "how many simulated days to compare with M/M/c/K queueing theory, instead of running the shop")
+	fs.Func("routers", "comma-separated routing strategies to compare: random, round-robin, shortest-queue, power-of-two", func(kinds string) error {
+		cfg.Routers = strings.Split(kinds, ",")
+		return nil
+	})
+	fs.StringVar(&cfg.Sweep.Output, "sweep", cfg.Sweep.Output, "run the shop over a grid of parameters, and write a CSV `file` with a row for each run, instead of running it once; - means standard output")
+	fs.Func("sweep-barbers", "comma-separated numbers of barbers to sweep over (default 1 up to -max-barbers)", func(s string) error {
+		return parseList(s, &cfg.Sweep.Barbers, strconv.Atoi)
+	})
+	fs.Func("sweep-capacity", "comma-separated numbers of seats to sweep over (default -capacity)", func(s string) error {
+		return parseList(s, &cfg.Sweep.Capacities, strconv.Atoi)
+	})
+	fs.Func("sweep-arrival-rate", "comma-separated average times between clients to sweep over (default -arrival-rate)", func(s string) error {
+		return parseList(s, &cfg.Sweep.ArrivalRates, parseDuration)
+	})
+	fs.Func("sweep-cut", "comma-separated haircut lengths to sweep over (default -cut)", func(s string) error {
+		return parseList(s, &cfg.Sweep.CutDurations, parseDuration)
+	})
+	fs.IntVar(&cfg.Sweep.Seeds, "sweep-seeds", cfg.Sweep.Seeds, "how many times to run each combination in a sweep, each time with a different seed")
+	fs.StringVar(&cfg.API, "api", cfg.API, "the `address` to serve the shop's HTTP API on")
+	fs.BoolVar(&cfg.Dashboard, "dashboard", cfg.Dashboard, "draw a live picture of the shop instead of logging, if the output is a terminal")
+	return fs
+}
+
+// parseList parses a comma-separated list, replacing whatever list was there before.
+func parseList[T any](s string, list *[]T, parse func(string) (T, error)) error {
+	*list = nil
+	for _, item := range strings.Split(s, ",") {
+		value, err := parse(strings.TrimSpace(item))
+		if err != nil {
+			return err
+		}
+		*list = append(*list, value)
+	}
+	return nil
+}
+
+// parseDuration parses a Duration the way Go writes durations, such as "1.5s".
+func parseDuration(s string) (Duration, error) {
+	d, err := time.ParseDuration(s)
+	return Duration(d), err
+}
+
+// readFile reads a JSON or YAML scenario file over cfg, so that whatever the file leaves out keeps its value.
+// Fields the file gets wrong, or that do not exist, are errors.
+func (cfg *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// YAML is read by way of JSON, so that both are checked the same way
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+	case ".yaml", ".yml":
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("%s: scenario files must be .json, .yaml or .yml", path)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// validate checks that the configuration describes a day that can actually happen, and lists everything that is
+// wrong with it if not.
+func (cfg Config) validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(cfg.Capacity >= 1, "capacity must be at least 1, not %d", cfg.Capacity)
+	check(cfg.Arrivals == "fixed" || cfg.Arrivals == "uniform" || cfg.Arrivals == "poisson" || cfg.Arrivals == "bursty" ||
+		cfg.Arrivals == "trace", "arrivals must be fixed, uniform, poisson, bursty or trace, not %q", cfg.Arrivals)
+	check(cfg.ArrivalRate > 0, "arrival_rate must be more than zero, not %v", time.Duration(cfg.ArrivalRate))
+	check(cfg.Arrivals != "trace" || cfg.ArrivalTrace != "", "arrival_trace must name a file when arrivals are trace")
+	check(cfg.CutDuration > 0, "cut_duration must be more than zero, not %v", time.Duration(cfg.CutDuration))
+	check(cfg.OpenFor > 0, "open_for must be more than zero, not %v", time.Duration(cfg.OpenFor))
+	check(cfg.GracePeriod >= 0, "grace_period cannot be negative")
+	check(cfg.Patience >= 0, "patience cannot be negative")
+	check(cfg.VIPShare >= 0 && cfg.VIPShare <= 1, "vip_share must be between 0 and 1, not %v", cfg.VIPShare)
+	check(cfg.AgingThreshold >= 0, "aging_threshold cannot be negative")
+	check(cfg.MaxBarbers >= 0, "max_barbers cannot be negative")
+	check(cfg.AppointmentGrace >= 0, "appointment_grace cannot be negative")
+	check(cfg.Report == "text" || cfg.Report == "json", "report must be text or json, not %q", cfg.Report)
+	check(cfg.ChainSize >= 0, "chain_size cannot be negative")
+	check(cfg.Analysis >= 0, "analysis cannot be negative")
+	if cfg.Sweep.Output != "" {
+		check(cfg.Sweep.Seeds > 0, "sweep: seeds must be at least 1, not %d", cfg.Sweep.Seeds)
+		check(len(cfg.Sweep.Barbers) > 0 || cfg.MaxBarbers > 0, "sweep: there must be at least one barber")
+		for _, n := range cfg.Sweep.Barbers {
+			check(n > 0, "sweep: barbers must be at least 1, not %d", n)
+		}
+		for _, capacity := range cfg.Sweep.Capacities {
+			check(capacity > 0, "sweep: capacities must be at least 1, not %d", capacity)
+		}
+		for _, d := range cfg.Sweep.ArrivalRates {
+			check(d > 0, "sweep: arrival_rates must be more than zero, not %v", time.Duration(d))
+		}
+		for _, d := range cfg.Sweep.CutDurations {
+			check(d > 0, "sweep: cut_durations must be more than zero, not %v", time.Duration(d))
+		}
+	}
+	for _, kind := range cfg.Routers {
+		_, err := newRouter(kind, nil)
+		check(err == nil, "routers: %v", err)
+	}
+
+	known := func(service Service) bool {
+		return service == ServiceCut || service == ServiceShave || service == ServiceColor
+	}
+	for service, d := range cfg.Menu {
+		check(known(service), "menu: there is no such service as %q", service)
+		check(d > 0, "menu: a %s must take more than zero, not %v", service, time.Duration(d))
+	}
+	for service, price := range cfg.Prices {
+		check(known(service), "prices: there is no such service as %q", service)
+		check(price >= 0, "prices: a %s cannot cost less than nothing", service)
+	}
+	check(cfg.TipRate >= 0, "tip_rate cannot be negative")
+	for _, w := range cfg.ServiceMix {
+		check(known(w.Service), "service_mix: there is no such service as %q", w.Service)
+		check(w.Weight >= 0, "service_mix: the weight of %s cannot be negative", w.Service)
+	}
+
+	check(len(cfg.Barbers) > 0, "there must be at least one barber")
+	names := make(map[string]bool)
+	shifts := make(map[string]BarberConfig)
+	for ii, barber := range cfg.Barbers {
+		name := barber.Name
+		if name == "" {
+			name = fmt.Sprintf("barber %d", ii+1)
+			check(false, "%s has no name", name)
+		}
+		check(!names[barber.Name], "there is more than one barber called %s", name)
+		names[barber.Name] = true
+		shifts[barber.Name] = barber
+		check(barber.Speed >= 0, "%s's speed cannot be negative", name)
+		for _, service := range barber.Services {
+			check(known(service), "%s does a %q, which is not a service", name, service)
+		}
+		check(barber.Start >= 0, "%s cannot start before the shop opens", name)
+		check(barber.End == 0 || barber.End > barber.Start, "%s's shift must end after it starts", name)
+		for _, b := range barber.Breaks {
+			check(b.Start >= barber.Start && b.Length > 0, "%s's break at %v must be during the shift, and take some time",
+				name, time.Duration(b.Start))
+		}
+	}
+	for _, a := range cfg.Appointments {
+		check(names[a.Barber], "the appointment at %v is with %q, who does not work here", time.Duration(a.At), a.Barber)
+		check(a.At >= 0, "the appointment with %s cannot be before the shop opens", a.Barber)
+		if shift, ok := shifts[a.Barber]; ok {
+			check(a.At >= shift.Start && (shift.End == 0 || a.At < shift.End),
+				"the appointment at %v is with %s, who is not at work then", time.Duration(a.At), a.Barber)
+		}
+		check(a.Service == "" || known(a.Service), "the appointment at %v is for a %q, which is not a service",
+			time.Duration(a.At), a.Service)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// barbers returns the barbers who work at the shop.
+func (cfg Config) barbers() []Barber {
+	var barbers []Barber
+	for _, bc := range cfg.Barbers {
+		barber := Barber{Name: bc.Name, Speed: bc.Speed, Services: bc.Services,
+			Schedule: Schedule{Start: time.Duration(bc.Start), End: time.Duration(bc.End)}}
+		for _, b := range bc.Breaks {
+			barber.Schedule.Breaks = append(barber.Schedule.Breaks, Break{Start: time.Duration(b.Start), Length: time.Duration(b.Length)})
+		}
+		barbers = append(barbers, barber)
+	}
+	return barbers
+}
+
+// appointments returns the day's appointment book.
+func (cfg Config) appointments() []Appointment {
+	var appointments []Appointment
+	for _, a := range cfg.Appointments {
+		appointments = append(appointments, Appointment{Name: a.Name, Barber: a.Barber, At: time.Duration(a.At),
+			Service: a.Service, Late: time.Duration(a.Late), NoShow: a.NoShow})
+	}
+	return appointments
+}
+
+// prices returns what each service costs, in cents.
+func (cfg Config) prices() map[Service]Cents {
+	prices := make(map[Service]Cents)
+	for service, price := range cfg.Prices {
+		prices[service] = dollars(price)
+	}
+	return prices
+}
+
+// menu returns how long each service takes an ordinary barber.
+func (cfg Config) menu() map[Service]time.Duration {
+	cut := time.Duration(cfg.CutDuration)
+	menu := map[Service]time.Duration{
+		ServiceCut:   cut,
+		ServiceShave: cut / 2,
+		ServiceColor: 2 * cut,
+	}
+	for service, d := range cfg.Menu {
+		menu[service] = time.Duration(d)
+	}
+	return menu
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeScenario(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_loadConfig(t *testing.T) {
+	yamlFile := writeScenario(t, "day.yaml", `
+capacity: 4
+cut_duration: 1.5s
+open_for: 60
+barbers:
+  - name: Frank
+  - name: Susan
+    services: [cut, color]
+    start: 2s
+    breaks: [{start: 5s, length: 1s}]
+appointments:
+  - {barber: Susan, at: 3s, service: color}
+`)
+	jsonFile := writeScenario(t, "day.json", `{
+	"capacity": 4,
+	"cut_duration": "1.5s",
+	"open_for": 60,
+	"barbers": [
+		{"name": "Frank"},
+		{"name": "Susan", "services": ["cut", "color"], "start": "2s", "breaks": [{"start": "5s", "length": "1s"}]}
+	],
+	"appointments": [{"barber": "Susan", "at": "3s", "service": "color"}]
+}`)
+
+	for _, path := range []string{yamlFile, jsonFile} {
+		// the flags win over the file, and the defaults fill in whatever neither of them says
+		cfg, err := loadConfig([]string{"-config", path, "-capacity", "6", "-seed", "7"}, defaultConfig())
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if cfg.Capacity != 6 || cfg.Seed != 7 || cfg.Report != reportFormat {
+			t.Errorf("%s: expected capacity 6, seed 7 and the default report, but got %d, %d and %q",
+				path, cfg.Capacity, cfg.Seed, cfg.Report)
+		}
+		if time.Duration(cfg.CutDuration) != 1500*time.Millisecond || time.Duration(cfg.OpenFor) != time.Minute {
+			t.Errorf("%s: expected 1.5s cuts for a minute, but got %v for %v",
+				path, time.Duration(cfg.CutDuration), time.Duration(cfg.OpenFor))
+		}
+
+		barbers := cfg.barbers()
+		if len(barbers) != 2 || barbers[1].Name != "Susan" || barbers[1].Schedule.Start != 2*time.Second ||
+			len(barbers[1].Schedule.Breaks) != 1 || barbers[1].Schedule.Breaks[0].Length != time.Second {
+			t.Errorf("%s: unexpected barbers %+v", path, barbers)
+		}
+		if appointments := cfg.appointments(); len(appointments) != 1 || appointments[0].At != 3*time.Second {
+			t.Errorf("%s: unexpected appointments %+v", path, appointments)
+		}
+		if menu := cfg.menu(); menu[ServiceColor] != 3*time.Second {
+			t.Errorf("%s: expected color to take twice as long as a cut, but it takes %v", path, menu[ServiceColor])
+		}
+	}
+
+	// the scenarios we keep with the code have to keep working
+	scenarios, _ := filepath.Glob("scenarios/*")
+	for _, path := range scenarios {
+		if _, err := loadConfig([]string{"-config", path}, defaultConfig()); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
+func Test_configLeavesDefaultsAlone(t *testing.T) {
+	mix := append([]ServiceWeight(nil), serviceMix...)
+	cut := prices[ServiceCut]
+	path := writeScenario(t, "mix.yaml", "service_mix: [{service: color, weight: 1}]\nrouters: [random]\nprices: {cut: 99}\n")
+	if _, err := loadConfig([]string{"-config", path}, defaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if len(serviceMix) != len(mix) || serviceMix[0] != mix[0] {
+		t.Errorf("expected the service mix to stay %v, but a scenario changed it to %v", mix, serviceMix)
+	}
+	if prices[ServiceCut] != cut {
+		t.Errorf("expected a cut to still cost %v, but a scenario changed it to %v", cut, prices[ServiceCut])
+	}
+	if routers[0] != "random" || routers[1] != "round-robin" {
+		t.Errorf("expected a scenario to leave the routers alone, but they are %v", routers)
+	}
+}
+
+func Test_barbersFlag(t *testing.T) {
+	// the default appointments are with Frank, who is not at work today
+	cfg, err := loadConfig([]string{"-barbers", "Bob,Alice"}, defaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Barbers) != 2 || len(cfg.Appointments) != 0 {
+		t.Errorf("expected Bob and Alice with no appointments, but got %+v and %+v", cfg.Barbers, cfg.Appointments)
+	}
+
+	cfg, err = loadConfig([]string{"-barbers", "Frank,Bob"}, defaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Appointments) != len(appointments) {
+		t.Errorf("expected Frank to keep his %d appointments, but he has %d", len(appointments), len(cfg.Appointments))
+	}
+}
+
+func Test_configValidation(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		args     []string
+		scenario string
+		want     []string
+	}{
+		{
+			name: "flags",
+			args: []string{"-capacity", "0", "-arrivals", "hourly", "-barbers", "Frank,Frank", "-report", "xml"},
+			want: []string{
+				"capacity must be at least 1, not 0",
+				`arrivals must be fixed, uniform, poisson, bursty or trace, not "hourly"`,
+				"there is more than one barber called Frank",
+				`report must be text or json, not "xml"`,
+			},
+		},
+		{
+			name:     "unknown field",
+			scenario: "seating: 4\n",
+			want:     []string{`unknown field "seating"`},
+		},
+		{
+			name:     "bad duration",
+			scenario: "cut_duration: a while\n",
+			want:     []string{`invalid duration "a while"`},
+		},
+		{
+			name:     "appointment with nobody",
+			scenario: "barbers: [{name: Frank}]\nappointments: [{barber: Bob, at: 1s}]\n",
+			want:     []string{`the appointment at 1s is with "Bob", who does not work here`},
+		},
+		{
+			name:     "appointment after the shift",
+			scenario: "barbers: [{name: Frank, start: 1s, end: 3s}]\nappointments: [{barber: Frank, at: 5s}, {barber: Frank, at: 0s}]\n",
+			want: []string{
+				"the appointment at 5s is with Frank, who is not at work then",
+				"the appointment at 0s is with Frank, who is not at work then",
+			},
+		},
+	} {
+		args := test.args
+		if test.scenario != "" {
+			args = append(args, "-config", writeScenario(t, "scenario.yaml", test.scenario))
+		}
+		_, err := loadConfig(args, defaultConfig())
+		if err == nil {
+			t.Errorf("%s: expected the configuration to be invalid", test.name)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: expected the error to say %q, but got: %v", test.name, want, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// showDashboard draws a picture of the shop on out, a terminal, and redraws it in place whenever something
+// happens in the shop or the shop opens or closes, and every refresh besides, so that the clock keeps ticking. It
+// draws the same things the shop's log tells of, so the log should be silenced while the dashboard is up. The
+// returned channel is closed once the shop has closed for the day, every barber has gone home, and the last
+// picture has been drawn.
+func (shop *BarberShop) showDashboard(out io.Writer, refresh time.Duration) <-chan bool {
+	events, unsubscribe := shop.events.subscribe()
+	changes, stopWatching := shop.watchState()
+	done := make(chan bool)
+
+	go func() {
+		defer close(done)
+		defer unsubscribe()
+		defer stopWatching()
+
+		ticker := time.NewTicker(refresh)
+		defer ticker.Stop()
+
+		lines := 0
+		draw := func() {
+			// go back up to the top of the last picture, and draw over it
+			if lines > 0 {
+				fmt.Fprintf(out, "\x1b[%dA", lines)
+			}
+			frame := renderDashboard(shop.status())
+			fmt.Fprint(out, "\r\x1b[J", frame)
+			lines = strings.Count(frame, "\n")
+		}
+
+		draw()
+		for {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					draw()
+					return
+				}
+			case _, ok := <-changes:
+				if !ok {
+					changes = nil
+				}
+			case <-ticker.C:
+			}
+			draw()
+		}
+	}()
+
+	return done
+}
+
+// renderDashboard draws a picture of the shop as it is in status: the waiting room's chairs, what each barber is
+// doing and for whom, how long until closing time, and how the day has gone so far.
+func renderDashboard(status shopStatus) string {
+	var b strings.Builder
+
+	// the clock
+	switch {
+	case status.State == ShopClosing:
+		atWork := 0
+		for _, barber := range status.Barbers {
+			if barber.State != BarberHome {
+				atWork++
+			}
+		}
+		fmt.Fprintf(&b, "%-24s closing, %d %s still at work\n", "The Sleeping Barber", atWork, plural(atWork, "barber"))
+	case status.State != ShopOpen || status.ClosesAt.IsZero():
+		fmt.Fprintf(&b, "%-24s %s\n", "The Sleeping Barber", status.State)
+	default:
+		left := status.ClosesAt.Sub(status.Now)
+		if left < 0 {
+			left = 0
+		}
+		fmt.Fprintf(&b, "%-24s open, closing in %v\n", "The Sleeping Barber", left.Round(100*time.Millisecond))
+	}
+
+	// the waiting room, a chair for every seat
+	waiting := status.Waiting
+	if waiting > status.Capacity {
+		waiting = status.Capacity
+	}
+	chairs := strings.Repeat("■", waiting) + strings.Repeat("□", status.Capacity-waiting)
+	fmt.Fprintf(&b, "%-24s [%s] %d/%d\n", "Waiting room", chairs, status.Waiting, status.Capacity)
+
+	// the barbers
+	for _, barber := range status.Barbers {
+		doing := strings.ReplaceAll(string(barber.State), "_", " ")
+		if barber.Client != "" {
+			doing += " " + barber.Client
+		}
+		fmt.Fprintf(&b, "  %-22s %-28s %d %s\n", barber.Name, doing, barber.Haircuts, plural(barber.Haircuts, "haircut"))
+	}
+
+	// the day so far
+	fmt.Fprintf(&b, "Served %d, turned away %d, gave up %d\n", status.Served, status.TurnedAway, status.Reneged)
+	return b.String()
+}
+
+// plural returns noun, made plural unless there is just one of it.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+	return noun + "s"
+}
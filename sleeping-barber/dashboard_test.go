@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_renderDashboard(t *testing.T) {
+	now := time.Date(2023, time.January, 2, 9, 0, 0, 0, time.UTC)
+	frame := renderDashboard(shopStatus{
+		State:      ShopOpen,
+		Open:       true,
+		Now:        now,
+		ClosesAt:   now.Add(4200 * time.Millisecond),
+		Waiting:    3,
+		Capacity:   5,
+		Served:     7,
+		TurnedAway: 2,
+		Reneged:    1,
+		Barbers: []barberStatus{
+			{Name: "Frank", State: BarberCutting, Client: "Client #4", Haircuts: 1},
+			{Name: "Susan", State: BarberOnBreak, Haircuts: 6},
+		},
+	})
+
+	for _, want := range []string{
+		"open, closing in 4.2s",
+		"[■■■□□] 3/5",
+		"cutting Client #4",
+		"1 haircut\n",
+		"on break",
+		"6 haircuts\n",
+		"Served 7, turned away 2, gave up 1",
+	} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("expected the dashboard to show %q, but got:\n%s", want, frame)
+		}
+	}
+}
+
+func Test_showDashboard(t *testing.T) {
+	shop := newTestShop(3, time.Second)
+	shop.addBarber(Barber{Name: "Frank"})
+
+	var out bytes.Buffer
+	done := shop.showDashboard(&out, time.Hour)
+	runTestDay(t, shop, 10*time.Second, &FixedArrivals{Interval: 500 * time.Millisecond})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the dashboard to finish once the shop had closed")
+	}
+
+	// every picture after the first is drawn over the one before, and the last one shows the end of the day
+	frames := strings.Split(out.String(), "\x1b[J")
+	if len(frames) < 3 {
+		t.Fatalf("expected the dashboard to be redrawn as the day went on, but it was drawn %d times", len(frames)-1)
+	}
+	last := frames[len(frames)-1]
+	report := shop.report()
+	for _, want := range []string{
+		"The Sleeping Barber      closed",
+		"home",
+		fmt.Sprintf("Served %d, turned away %d", report.ClientsServed, report.ClientsTurnedAway),
+	} {
+		if !strings.Contains(last, want) {
+			t.Errorf("expected the last picture to show %q, but got:\n%s", want, last)
+		}
+	}
+}
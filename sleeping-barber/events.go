@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// EventKind says what happened in the shop.
+type EventKind string
+
+const (
+	EventClientArrived    EventKind = "client_arrived"
+	EventClientTurnedAway EventKind = "client_turned_away"
+	EventClientReneged    EventKind = "client_reneged"
+	EventBarberNapping    EventKind = "barber_napping"
+	EventBarberWoken      EventKind = "barber_woken"
+	EventCutStarted       EventKind = "cut_started"
+	EventCutFinished      EventKind = "cut_finished"
+	EventBarberHome       EventKind = "barber_home"
+	EventBarberPanicked   EventKind = "barber_panicked"
+)
+
+// Event is something that happened in the shop, as told to whoever is watching.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Kind     EventKind `json:"event"`
+	ClientID int       `json:"client_id,omitempty"`
+	Client   string    `json:"client,omitempty"`
+	BarberID int       `json:"barber_id,omitempty"`
+	Barber   string    `json:"barber,omitempty"`
+}
+
+// eventBus passes the shop's events on to everyone who has subscribed to them. A subscriber who does not keep
+// up misses events rather than holding up the shop.
+type eventBus struct {
+	mutex       sync.Mutex
+	subscribers map[chan Event]bool
+	closed      bool
+}
+
+// subscribe returns a channel of the shop's events from now on, and a function to call once no more events are
+// wanted. The channel is closed when the shop closes the bus, or when unsubscribe is called.
+func (bus *eventBus) subscribe() (events <-chan Event, unsubscribe func()) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	ch := make(chan Event, 64)
+	if bus.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if bus.subscribers == nil {
+		bus.subscribers = make(map[chan Event]bool)
+	}
+	bus.subscribers[ch] = true
+
+	return ch, func() {
+		bus.mutex.Lock()
+		defer bus.mutex.Unlock()
+		if bus.subscribers[ch] {
+			delete(bus.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish sends an event to every subscriber who has room for it.
+func (bus *eventBus) publish(event Event) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	for ch := range bus.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// close ends every subscriber's channel once there is nothing more to tell.
+func (bus *eventBus) close() {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	for ch := range bus.subscribers {
+		close(ch)
+	}
+	bus.subscribers = nil
+	bus.closed = true
+}
+
+// event records that something happened to a client, a barber, or both; either may be nil. The event is written
+// to the shop's event log, and passed on to the shop's subscribers.
+func (shop *BarberShop) event(kind EventKind, client *Client, barber *barberStats) {
+	event := Event{Time: shop.Clock.Now(), Kind: kind}
+	if client != nil {
+		event.ClientID, event.Client = client.ID, client.Name
+	}
+	if barber != nil {
+		event.BarberID, event.Barber = barber.id, barber.Name
+	}
+	shop.logEvent(event)
+	shop.events.publish(event)
+}
+
+// logEvent writes an event to the shop's event log as a line of JSON. Unlike subscribers, the log never misses
+// an event; if it cannot be written, though, the shop gives up on it rather than closing for the day.
+func (shop *BarberShop) logEvent(event Event) {
+	shop.logMutex.Lock()
+	defer shop.logMutex.Unlock()
+
+	if shop.EventLog == nil || shop.logErr != nil {
+		return
+	}
+	line, err := json.Marshal(event)
+	if err == nil {
+		_, err = shop.EventLog.Write(append(line, '\n'))
+	}
+	if err != nil {
+		shop.logErr = err
+		color.Red("*** Error writing the event log, so no more events will be written: %v", err)
+	}
+}
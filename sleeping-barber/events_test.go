@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func Test_eventLog(t *testing.T) {
+	var log bytes.Buffer
+	shop := newTestShop(3, time.Second)
+	shop.EventLog = &log
+	opened := shop.Clock.Now()
+	shop.addBarber(Barber{Name: "Frank"})
+	runTestDay(t, shop, 3*time.Second, &TraceArrivals{Offsets: []time.Duration{time.Second}})
+
+	type entry struct {
+		at       time.Duration
+		kind     EventKind
+		clientID int
+		barberID int
+	}
+	expected := []entry{
+		{0, EventBarberNapping, 0, 1},
+		{time.Second, EventClientArrived, 1, 0},
+		{time.Second, EventBarberWoken, 0, 1},
+		{time.Second, EventCutStarted, 1, 1},
+		{2 * time.Second, EventCutFinished, 1, 1},
+		{2 * time.Second, EventBarberNapping, 0, 1},
+		{3 * time.Second, EventBarberWoken, 0, 1},
+		{3 * time.Second, EventBarberHome, 0, 1},
+	}
+
+	var got []entry
+	decoder := json.NewDecoder(&log)
+	for decoder.More() {
+		var event Event
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatalf("the event log is not JSON lines: %v", err)
+		}
+		got = append(got, entry{event.Time.Sub(opened), event.Kind, event.ClientID, event.BarberID})
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d events but got %d: %v", len(expected), len(got), got)
+	}
+	for ii := range expected {
+		if got[ii] != expected[ii] {
+			t.Errorf("event %d: expected %v but got %v", ii, expected[ii], got[ii])
+		}
+	}
+}
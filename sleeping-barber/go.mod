@@ -3,8 +3,12 @@ module sleeping-barber
 go 1.18
 
 require (
-	github.com/fatih/color v1.14.1 // indirect
+	github.com/fatih/color v1.14.1
+	github.com/mattn/go-isatty v0.0.17
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.17 // indirect
 	golang.org/x/sys v0.3.0 // indirect
 )
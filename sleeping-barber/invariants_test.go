@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// invariantScenarios are days at the shop that between them use everything the shop can do. Each one sets up a
+// fresh shop, and returns how its clients arrive.
+var invariantScenarios = []struct {
+	name  string
+	setup func(shop *BarberShop, rng *rand.Rand) ArrivalProcess
+}{
+	{
+		name: "one barber",
+		setup: func(shop *BarberShop, rng *rand.Rand) ArrivalProcess {
+			shop.addBarber(Barber{Name: "Frank"})
+			return &UniformArrivals{Mean: 500 * time.Millisecond, Rand: rng}
+		},
+	},
+	{
+		name: "busy day",
+		setup: func(shop *BarberShop, rng *rand.Rand) ArrivalProcess {
+			shop.ShopCapacity = 5
+			shop.Menu = map[Service]time.Duration{ServiceCut: time.Second, ServiceShave: 500 * time.Millisecond, ServiceColor: 2 * time.Second}
+			shop.ChooseService = chooseServices(serviceMix, rand.New(rand.NewSource(rng.Int63())))
+			patience := rand.New(rand.NewSource(rng.Int63()))
+			shop.ChoosePatience = func() time.Duration { return time.Duration(patience.ExpFloat64() * float64(2*time.Second)) }
+			vips := rand.New(rand.NewSource(rng.Int63()))
+			shop.ChooseClass = func() ClientClass {
+				if vips.Float64() < 0.2 {
+					return ClassVIP
+				}
+				return ClassRegular
+			}
+			shop.AgingThreshold = time.Second
+			shop.ChooseServiceTime = exponentialServiceTimes(rand.New(rand.NewSource(rng.Int63())))
+			shop.addBarber(Barber{Name: "Frank"})
+			shop.addBarber(Barber{Name: "Susan", Speed: 1.5, Services: []Service{ServiceCut, ServiceColor}})
+			shop.addBarber(Barber{Name: "Kelly", Services: []Service{ServiceShave}})
+			return &PoissonArrivals{Mean: 200 * time.Millisecond, Rand: rng}
+		},
+	},
+	{
+		name: "shifts and appointments",
+		setup: func(shop *BarberShop, rng *rand.Rand) ArrivalProcess {
+			shop.addBarber(Barber{Name: "Frank", Schedule: Schedule{
+				Start:  time.Second,
+				Breaks: []Break{{Start: 4 * time.Second, Length: time.Second}},
+			}})
+			shop.addBarber(Barber{Name: "Bob", Schedule: Schedule{End: 6 * time.Second}})
+			shop.Appointments = []Appointment{
+				{Name: "Early", Barber: "Frank", At: 2 * time.Second},
+				{Name: "Late", Barber: "Frank", At: 3 * time.Second, Late: 700 * time.Millisecond},
+				{Name: "Very late", Barber: "Bob", At: 3 * time.Second, Late: 3 * time.Second},
+				{Name: "No-show", Barber: "Bob", At: 5 * time.Second, NoShow: true},
+				{Name: "After hours", Barber: "Frank", At: 20 * time.Second},
+				{Name: "Stranded", Barber: "Bob", At: 7 * time.Second}, // Bob has gone home by then
+			}
+			shop.AppointmentGrace = time.Second
+			return &PoissonArrivals{Mean: 600 * time.Millisecond, Rand: rng}
+		},
+	},
+	{
+		// clients come in faster than the barbers can keep up with, so the waiting room is full more often than not,
+		// and the scissors break part way through a haircut, once the client's seat has been taken by somebody else
+		name: "panics",
+		setup: func(shop *BarberShop, rng *rand.Rand) ArrivalProcess {
+			var mutex sync.Mutex
+			scissors := rand.New(rand.NewSource(rng.Int63()))
+			shop.ChooseServiceTime = func(d time.Duration) time.Duration {
+				mutex.Lock()
+				broke, after := scissors.Float64() < 0.2, time.Duration(scissors.Float64()*float64(d))
+				mutex.Unlock()
+				if broke {
+					shop.Clock.Sleep(after)
+					panic("scissors broke")
+				}
+				return d
+			}
+			shop.RestartBackoff = 200 * time.Millisecond
+			shop.Appointments = []Appointment{{Name: "Booked", Barber: "Frank", At: 3 * time.Second}}
+			shop.addBarber(Barber{Name: "Frank"})
+			shop.addBarber(Barber{Name: "Bob"})
+			return &PoissonArrivals{Mean: 200 * time.Millisecond, Rand: rng}
+		},
+	},
+	{
+		name: "autoscaling",
+		setup: func(shop *BarberShop, rng *rand.Rand) ArrivalProcess {
+			shop.ShopCapacity = 6
+			shop.Autoscaling = &AutoscalePolicy{
+				Interval:   500 * time.Millisecond,
+				HighWater:  0.5,
+				LowWater:   0.2,
+				Sustain:    2,
+				MinBarbers: 1,
+				MaxBarbers: 4,
+				Hire:       Barber{Name: "Temp", Speed: 1},
+			}
+			shop.addBarber(Barber{Name: "Frank"})
+			arrivals, _ := newArrivalProcess("bursty", 300*time.Millisecond, "", rng)
+			return arrivals
+		},
+	},
+}
+
+// Test_invariants runs whole days at the shop, each many times over, and checks what must be true however the
+// day goes. The shop is all about goroutines getting along, so make test runs it with the race detector.
+func Test_invariants(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+
+	for _, scenario := range invariantScenarios {
+		for seed := int64(1); seed <= 30; seed++ {
+			shop := newTestShop(3, time.Second)
+			var log bytes.Buffer
+			shop.EventLog = &log
+			arrivals := scenario.setup(shop, rand.New(rand.NewSource(seed)))
+			runTestDay(t, shop, 10*time.Second, arrivals)
+
+			checkInvariants(t, scenario.name, seed, shop, readEvents(t, &log))
+		}
+	}
+
+	// once every shop has closed, everything the shops set going has finished too
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if now := runtime.NumGoroutine(); now > goroutines {
+		stacks := make([]byte, 1<<16)
+		stacks = stacks[:runtime.Stack(stacks, true)]
+		t.Errorf("expected %d goroutines once the shops had closed, but there are %d:\n%s", goroutines, now, stacks)
+	}
+}
+
+// readEvents reads back an event log.
+func readEvents(t *testing.T, log *bytes.Buffer) []Event {
+	t.Helper()
+	var events []Event
+	scanner := bufio.NewScanner(log)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("bad event %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// checkInvariants checks what must be true of every day at the shop, once it is over.
+func checkInvariants(t *testing.T, scenario string, seed int64, shop *BarberShop, events []Event) {
+	t.Helper()
+	report := shop.report()
+
+	// no client is served twice, and nobody who was turned away, or gave up, is served at all
+	arrived, finished, started, left := map[int]bool{}, map[int]int{}, map[int]bool{}, map[int]EventKind{}
+	home := make(map[int]int)
+	for _, event := range events {
+		switch event.Kind {
+		case EventClientArrived:
+			arrived[event.ClientID] = true
+		case EventCutStarted:
+			started[event.ClientID] = true
+		case EventCutFinished:
+			finished[event.ClientID]++
+		case EventClientTurnedAway, EventClientReneged:
+			left[event.ClientID] = event.Kind
+		case EventBarberHome:
+			home[event.BarberID]++
+		}
+	}
+	for id, n := range finished {
+		if n > 1 {
+			t.Errorf("%s, seed %d: client %d was served %d times", scenario, seed, id, n)
+		}
+	}
+	for id, why := range left {
+		if started[id] {
+			t.Errorf("%s, seed %d: client %d was served after %s", scenario, seed, id, why)
+		}
+	}
+	served := make(map[int]bool)
+	for _, client := range shop.clientsServed() {
+		if served[client.ID] {
+			t.Errorf("%s, seed %d: %s is counted as served twice", scenario, seed, client)
+		}
+		served[client.ID] = true
+	}
+
+	// every client who walked in is accounted for exactly once
+	accounted := report.ClientsServed + report.ClientsTurnedAway + report.ClientsReneged + report.ClientsAfterHours
+	if accounted != len(arrived) {
+		t.Errorf("%s, seed %d: %d clients arrived, but %d are accounted for", scenario, seed, len(arrived), accounted)
+	}
+
+	// every barber goes home exactly once
+	shop.statsMutex.Lock()
+	barbers := len(shop.barbers)
+	shop.statsMutex.Unlock()
+	for id := 1; id <= barbers; id++ {
+		if home[id] != 1 {
+			t.Errorf("%s, seed %d: barber %d went home %d times", scenario, seed, id, home[id])
+		}
+	}
+
+	// the waiting room never has more clients sitting in it than it has seats, and is empty once the last barber
+	// has left
+	shop.mutex.Lock()
+	peak, waiting, atWork, state := shop.room.peak, shop.room.len(), shop.atWork, shop.state
+	shop.mutex.Unlock()
+	if peak > shop.ShopCapacity {
+		t.Errorf("%s, seed %d: %d clients were sitting at once, with only %d seats", scenario, seed, peak, shop.ShopCapacity)
+	}
+	if waiting != 0 || atWork != 0 || state != ShopClosed {
+		t.Errorf("%s, seed %d: the shop is %s with %d clients waiting and %d barbers at work after the day was over",
+			scenario, seed, state, waiting, atWork)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cents is an amount of money. It is kept in whole cents, so that adding up a day's takings never gains or loses
+// a fraction of one, and only written as dollars.
+type Cents int64
+
+// dollars returns an amount of dollars, as prices are written, in cents.
+func dollars(d float64) Cents {
+	return Cents(math.Round(d * 100))
+}
+
+// String writes the amount in dollars, such as "$12.50".
+func (c Cents) String() string {
+	if c < 0 {
+		return "-" + (-c).String()
+	}
+	return fmt.Sprintf("$%d.%02d", c/100, c%100)
+}
+
+// MarshalJSON writes the amount as a number of dollars, such as 12.50, to the cent.
+func (c Cents) MarshalJSON() ([]byte, error) {
+	sign := ""
+	if c < 0 {
+		sign, c = "-", -c
+	}
+	return []byte(fmt.Sprintf("%s%d.%02d", sign, c/100, c%100)), nil
+}
+
+// Earnings adds up what a group of clients paid.
+type Earnings struct {
+	Clients int
+	Revenue Cents // what the services cost
+	Tips    Cents
+}
+
+// Total is everything the clients paid, tips included.
+func (e Earnings) Total() Cents {
+	return e.Revenue + e.Tips
+}
+
+func (e *Earnings) add(price, tip Cents) {
+	e.Clients++
+	e.Revenue += price
+	e.Tips += tip
+}
+
+// HourEarnings is what the shop earned in the hour starting at Hour, by the shop's clock.
+type HourEarnings struct {
+	Hour time.Time
+	Earnings
+}
+
+// Revenue is the money side of the shop's day.
+type Revenue struct {
+	Earnings
+	ByBarber       map[string]Earnings
+	ByService      map[Service]Earnings
+	ByHour         []HourEarnings // in order, leaving out hours when nobody paid
+	LostTurnedAway Cents          // what the clients who were turned away would have paid, without tips
+	LostReneged    Cents          // what the clients who gave up waiting would have paid, without tips
+}
+
+// ledgerEntry is one client paying, or one client the shop lost.
+type ledgerEntry struct {
+	at      time.Time
+	barber  string
+	service Service
+	price   Cents
+	tip     Cents
+	lost    EventKind // why the shop lost the client; empty if the client paid
+}
+
+// ledger is the shop's book of takings. It is safe for concurrent use, since every barber takes money.
+type ledger struct {
+	mutex   sync.Mutex
+	entries []ledgerEntry
+}
+
+// paid records a client paying the barber for a service.
+func (l *ledger) paid(at time.Time, barber string, service Service, price, tip Cents) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = append(l.entries, ledgerEntry{at: at, barber: barber, service: service, price: price, tip: tip})
+}
+
+// lost records a client who left without being served, and what the service would have cost. why is
+// EventClientTurnedAway or EventClientReneged.
+func (l *ledger) lost(at time.Time, service Service, price Cents, why EventKind) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = append(l.entries, ledgerEntry{at: at, service: service, price: price, lost: why})
+}
+
+// revenue adds up the ledger.
+func (l *ledger) revenue() Revenue {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	r := Revenue{ByBarber: make(map[string]Earnings), ByService: make(map[Service]Earnings)}
+	byHour := make(map[time.Time]*HourEarnings)
+	for _, entry := range l.entries {
+		switch entry.lost {
+		case EventClientTurnedAway:
+			r.LostTurnedAway += entry.price
+			continue
+		case EventClientReneged:
+			r.LostReneged += entry.price
+			continue
+		}
+
+		r.add(entry.price, entry.tip)
+
+		barber := r.ByBarber[entry.barber]
+		barber.add(entry.price, entry.tip)
+		r.ByBarber[entry.barber] = barber
+
+		service := r.ByService[entry.service]
+		service.add(entry.price, entry.tip)
+		r.ByService[entry.service] = service
+
+		hour := entry.at.Truncate(time.Hour)
+		if byHour[hour] == nil {
+			byHour[hour] = &HourEarnings{Hour: hour}
+		}
+		byHour[hour].add(entry.price, entry.tip)
+	}
+
+	for _, hour := range byHour {
+		r.ByHour = append(r.ByHour, *hour)
+	}
+	sort.Slice(r.ByHour, func(i, j int) bool { return r.ByHour[i].Hour.Before(r.ByHour[j].Hour) })
+	return r
+}
+
+// price is what the shop charges for a service.
+func (shop *BarberShop) price(service Service) Cents {
+	return shop.Prices[service]
+}
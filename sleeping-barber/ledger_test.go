@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_ledgerConcurrent(t *testing.T) {
+	// every barber takes money at once, and the ledger must not lose a cent of it
+	var l ledger
+	at := time.Date(2023, time.January, 2, 9, 30, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	for _, name := range []string{"Frank", "Susan", "Kelly", "Pat"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			for ii := 0; ii < 100; ii++ {
+				l.paid(at.Add(time.Duration(ii)*time.Minute), name, ServiceCut, 2500, 500)
+				l.lost(at, ServiceShave, 1500, EventClientTurnedAway)
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	r := l.revenue()
+	if r.Clients != 400 || r.Revenue != 1000000 || r.Tips != 200000 || r.Total() != 1200000 {
+		t.Errorf("expected 400 clients to pay $10000 and tip $2000, but got %+v", r.Earnings)
+	}
+	if frank := r.ByBarber["Frank"]; frank.Clients != 100 || frank.Total() != 300000 {
+		t.Errorf("expected Frank to take $3000 from 100 clients, but got %+v", frank)
+	}
+	if r.LostTurnedAway != 600000 || r.LostReneged != 0 {
+		t.Errorf("expected $6000 lost to turned-away clients, but got %v and %v", r.LostTurnedAway, r.LostReneged)
+	}
+
+	// the payments run from 9:30 to 11:09, so they fall into three hours, in order
+	if len(r.ByHour) != 3 || r.ByHour[0].Clients != 120 || r.ByHour[1].Clients != 240 || r.ByHour[2].Clients != 40 {
+		t.Errorf("expected 120, 240 and 40 clients in three hours, but got %+v", r.ByHour)
+	}
+}
+
+func Test_revenue(t *testing.T) {
+	shop := newTestShop(3, time.Second)
+	shop.Prices = map[Service]Cents{ServiceCut: 2000}
+	shop.ChooseTip = func(client *Client) Cents { return 200 }
+	shop.addBarber(Barber{Name: "Frank"})
+	runTestDay(t, shop, 10*time.Second, &FixedArrivals{Interval: 500 * time.Millisecond})
+
+	report := shop.report()
+	served := Cents(report.ClientsServed)
+	if r := report.Revenue; r.Revenue != 2000*served || r.Tips != 200*served || r.ByService[ServiceCut].Clients != report.ClientsServed {
+		t.Errorf("expected %v clients to pay $20 and tip $2 each, but got %+v", served, r)
+	}
+	if lost := report.Revenue.LostTurnedAway; lost != 2000*Cents(report.ClientsTurnedAway) {
+		t.Errorf("expected $20 lost for each of %d clients turned away, but got %v", report.ClientsTurnedAway, lost)
+	}
+}
+
+func Test_cents(t *testing.T) {
+	// a hundred tips of 7 cents, written the way prices are, add up to exactly $7, which as dollars they would not
+	var e Earnings
+	for ii := 0; ii < 100; ii++ {
+		e.add(dollars(0.1), dollars(0.07))
+	}
+	if e.Tips != 700 || e.Tips.String() != "$7.00" {
+		t.Errorf("expected $7.00 in tips, but got %v", e.Tips)
+	}
+
+	for _, test := range []struct {
+		amount Cents
+		text   string
+		json   string
+	}{
+		{0, "$0.00", "0.00"},
+		{6061, "$60.61", "60.61"},
+		{-5, "-$0.05", "-0.05"},
+	} {
+		if got := test.amount.String(); got != test.text {
+			t.Errorf("expected %d cents to be written %q, but got %q", int64(test.amount), test.text, got)
+		}
+		if got, _ := json.Marshal(test.amount); string(got) != test.json {
+			t.Errorf("expected %d cents to be written as JSON %s, but got %s", int64(test.amount), test.json, got)
+		}
+	}
+}
@@ -23,51 +23,350 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
 	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 )
 
-// variables
+// variables, which are the defaults for a scenario file and the command-line flags
 var seatingCapacity = 10
-var arrivalRate = 100
+var arrivalRate = 100             // the average number of milliseconds between clients
+var arrivalKind = "uniform"       // how clients arrive: "fixed", "uniform", "poisson", "bursty" or "trace"
+var arrivalTrace = "arrivals.csv" // the arrival times replayed by the "trace" arrival process
 var cutDuration = 1000 * time.Millisecond
 var timeOpen = 10 * time.Second
+var gracePeriod = 30 * time.Second   // how long the barbers may take to finish up after closing time
+var autoscaling = false              // whether to call in extra barbers when the waiting room fills up
+var maxBarbers = 4                   // the most barbers who may be at work at once, when autoscaling
+var clientPatience = 5 * time.Second // how long clients are willing to wait, on average; zero means forever
+var vipShare = 0.2                   // the fraction of clients who are VIPs, and are served first
+var agingThreshold = 3 * time.Second // how long a regular client waits before being served like a VIP
+var reportFormat = "text"            // how the end-of-day report is printed, either "text" or "json"
+var eventLog = ""                    // the file every event in the shop is written to as JSON lines; empty means none
+var chainSize = 0                    // how many shops to compare client routing strategies over; zero means just the one shop
+var analysisRuns = 0                 // how many simulated days to compare with queueing theory; zero means none
+var sweepSeeds = 3                   // how many times to run each combination of parameters in a sweep
+var apiAddr = ""                     // where to serve the shop's HTTP API, such as "localhost:8080"; empty means nowhere
+var dashboard = false                // whether to draw a live picture of the shop instead of logging, on a terminal
+
+// the barbers who work at the shop, and what they can do
+var barbers = []Barber{
+	{Name: "Frank", Speed: 1, Services: []Service{ServiceCut, ServiceShave, ServiceColor}},
+}
+
+// the client routing strategies compared over a chain of shops
+var routers = []string{"random", "round-robin", "shortest-queue", "power-of-two"}
+
+// the day's appointment book, and how late clients may be for an appointment before they lose the slot
+var appointments = []Appointment{
+	{Name: "Mrs. Jones", Barber: "Frank", At: 3 * time.Second, Late: 500 * time.Millisecond},
+	{Name: "Mr. Smith", Barber: "Frank", At: 6 * time.Second, Service: ServiceShave},
+}
+var appointmentGrace = 2 * time.Second
+
+// what each service costs, in dollars, and how much clients tip on average, as a fraction of the price
+var prices = map[Service]float64{
+	ServiceCut:   25,
+	ServiceShave: 15,
+	ServiceColor: 60,
+}
+var tipRate = 0.15
+
+// how often clients ask for each service
+var serviceMix = []ServiceWeight{
+	{Service: ServiceCut, Weight: 7},
+	{Service: ServiceShave, Weight: 2},
+	{Service: ServiceColor, Weight: 1},
+}
 
 func main() {
+	// read the day's scenario, and the command line
+	cfg, err := loadConfig(os.Args[1:], defaultConfig())
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		color.Red("*** %v", err)
+		os.Exit(2)
+	}
+
 	// seed our random number generator
-	rand.Seed(time.Now().UnixNano())
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	rand.Seed(cfg.Seed)
+
+	// run the shop over a grid of parameters, instead of running it once; the CSV may be going to standard output,
+	// so nothing else is printed there
+	if cfg.Sweep.Output != "" {
+		sweepShop(cfg)
+		return
+	}
 
 	// print welcome message
 	color.Yellow("---------------------------")
 	color.Yellow("The Sleeping Barber Problem")
 	color.Yellow("---------------------------")
 
+	// check the shop against queueing theory, instead of running it for real
+	if cfg.Analysis > 0 {
+		analyzeShop(cfg)
+		return
+	}
+
+	// compare how a chain of shops does under each routing strategy, instead of running a single shop
+	if cfg.ChainSize > 0 {
+		compareChains(cfg)
+		return
+	}
+
+	// decide how clients will arrive
+	arrivals, err := newArrivalProcess(cfg.Arrivals, time.Duration(cfg.ArrivalRate), cfg.ArrivalTrace,
+		rand.New(rand.NewSource(rand.Int63())))
+	if err != nil {
+		color.Red("*** Error setting up client arrivals: %v", err)
+		return
+	}
+
 	// create channels if we need any
-	clientChan := make(chan string, seatingCapacity)
 	doneChan := make(chan bool)
 
 	// create the barbershop
 	shop := BarberShop{
-		ShopCapacity:    seatingCapacity,
-		HairCurDuration: cutDuration,
-		NumberOfBarbers: 0,
-		ClientChan:      clientChan,
-		BarberDoneChan:  doneChan,
-		Open:            true,
+		ShopCapacity:     cfg.Capacity,
+		HairCurDuration:  time.Duration(cfg.CutDuration),
+		NumberOfBarbers:  0,
+		BarberDoneChan:   doneChan,
+		GracePeriod:      time.Duration(cfg.GracePeriod),
+		Clock:            RealClock{},
+		Menu:             cfg.menu(),
+		ChooseService:    chooseServices(cfg.ServiceMix, rand.New(rand.NewSource(rand.Int63()))),
+		AgingThreshold:   time.Duration(cfg.AgingThreshold),
+		Appointments:     cfg.appointments(),
+		AppointmentGrace: time.Duration(cfg.AppointmentGrace),
+		Prices:           cfg.prices(),
+	}
+
+	if cfg.Autoscaling {
+		shop.Autoscaling = &AutoscalePolicy{
+			Interval:   time.Duration(cfg.CutDuration) / 2,
+			HighWater:  0.7,
+			LowWater:   0.2,
+			Sustain:    3,
+			MinBarbers: len(cfg.Barbers),
+			MaxBarbers: cfg.MaxBarbers,
+			Hire:       Barber{Name: "Temp", Speed: 1},
+		}
+	}
+
+	// a few clients are VIPs
+	vips := rand.New(rand.NewSource(rand.Int63()))
+	shop.ChooseClass = func() ClientClass {
+		if vips.Float64() < cfg.VIPShare {
+			return ClassVIP
+		}
+		return ClassRegular
+	}
+
+	// some clients are more patient than others
+	if cfg.Patience > 0 {
+		patience := rand.New(rand.NewSource(rand.Int63()))
+		shop.ChoosePatience = func() time.Duration {
+			return time.Duration(patience.ExpFloat64() * float64(cfg.Patience))
+		}
+	}
+
+	// some clients tip more than others, and some not at all
+	if cfg.TipRate > 0 {
+		tips := rand.New(rand.NewSource(rand.Int63()))
+		shop.ChooseTip = func(client *Client) Cents {
+			return Cents(math.Round(float64(shop.price(client.Service)) * cfg.TipRate * 2 * tips.Float64()))
+		}
+	}
+
+	// keep a record of everything that happens, for later
+	if cfg.EventLog != "" {
+		f, err := os.Create(cfg.EventLog)
+		if err != nil {
+			color.Red("*** Error creating the event log: %v", err)
+			return
+		}
+		defer f.Close()
+		shop.EventLog = f
+	}
+
+	// let clients walk in over HTTP, and let anyone watch what goes on
+	if cfg.API != "" {
+		server := &http.Server{Addr: cfg.API, Handler: shop.Handler()}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				color.Red("*** Error serving the API: %v", err)
+			}
+		}()
+		defer server.Close()
+		color.Green("The shop's API is at http://%s/", cfg.API)
 	}
 
 	color.Green("The shop is open for the day!")
 
 	// add barbers
-	shop.addBarber("Frank")
+	for _, barber := range cfg.barbers() {
+		shop.addBarber(barber)
+	}
+
+	// run the barbershop until closing time, or until we are interrupted, and until every barber has gone home
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.OpenFor))
+	defer cancel()
 
-	// start the barbershop as a goroutine
+	// draw the shop as it goes, if there is a terminal to draw it on; otherwise, just log what happens
+	var dashboardDone <-chan bool
+	logOutput := color.Output
+	if cfg.Dashboard {
+		if isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+			color.Output = io.Discard
+			dashboardDone = shop.showDashboard(os.Stdout, 100*time.Millisecond)
+		} else {
+			color.Yellow("The output is not a terminal, so there is no dashboard.")
+		}
+	}
 
-	// add clients
+	err = shop.Run(ctx, arrivals)
+	if dashboardDone != nil {
+		<-dashboardDone
+		color.Output = logOutput
+	}
+	if err != nil {
+		color.Red("*** %v", err)
+	}
 
-	// block until the barbershop is closed
+	// print a summary of the day
+	report := shop.report()
+	if cfg.Report == "json" {
+		out, err := report.JSON()
+		if err != nil {
+			color.Red("*** Error writing the report: %v", err)
+			return
+		}
+		fmt.Println(out)
+	} else {
+		report.print()
+	}
+}
+
+// sweepShop runs the shop for every combination of parameters in cfg.Sweep, several at once, and writes how
+// every run went to cfg.Sweep.Output as CSV.
+func sweepShop(cfg Config) {
+	out := os.Stdout
+	if cfg.Sweep.Output != "-" {
+		f, err := os.Create(cfg.Sweep.Output)
+		if err != nil {
+			color.Red("*** Error creating the sweep's CSV: %v", err)
+			return
+		}
+		defer f.Close()
+		out = f
+	}
 
-	time.Sleep(5 * time.Second)
+	// thousands of clients come and go, so keep quiet about each of them
+	points := cfg.sweepPoints(cfg.Seed)
+	logOutput := color.Output
+	color.Output = io.Discard
+	results, err := runSweep(points, runtime.GOMAXPROCS(0), cfg.sweepDay)
+	color.Output = logOutput
+	if err != nil {
+		color.Red("*** Error running the sweep: %v", err)
+		return
+	}
+
+	if err := writeSweepCSV(out, results); err != nil {
+		color.Red("*** Error writing the sweep's CSV: %v", err)
+		return
+	}
+	if out != os.Stdout {
+		color.Green("Wrote %d runs to %s.", len(results), cfg.Sweep.Output)
+	}
+}
+
+// analyzeShop compares the shop with what queueing theory predicts for it, over cfg.Analysis simulated days. The
+// theory only covers clients who arrive as a Poisson process, services that take an exponentially distributed
+// time, identical barbers, and clients who never give up, so the days are run that way, whatever cfg says; the
+// only service is a cut, and it takes cfg.CutDuration on average.
+func analyzeShop(cfg Config) {
+	model := MMcK{
+		ArrivalRate: 1 / time.Duration(cfg.ArrivalRate).Seconds(),
+		ServiceRate: 1 / time.Duration(cfg.CutDuration).Seconds(),
+		C:           len(cfg.Barbers),
+		K:           len(cfg.Barbers) + cfg.Capacity,
+	}
+
+	// thousands of clients come and go, so keep quiet about each of them
+	logOutput := color.Output
+	color.Output = io.Discard
+	analysis, err := analyzeQueue(model, time.Duration(cfg.OpenFor), cfg.Analysis, rand.Int63())
+	color.Output = logOutput
+	if err != nil {
+		color.Red("*** Error analyzing the shop: %v", err)
+		return
+	}
+	printAnalysis(analysis)
+}
+
+// compareChains runs the same day at a chain of cfg.ChainSize shops under each routing strategy, on a simulated
+// clock so that it takes no time at all, and prints how each strategy did. The chain gets cfg.ChainSize times as
+// many clients as a single shop would.
+func compareChains(cfg Config) {
+	// the routers get a seed of their own, so that where clients are sent has nothing to do with when they come
+	seed, routingSeed := rand.Int63(), rand.Int63()
+	reports, err := compareRouters(cfg.Routers, time.Duration(cfg.OpenFor), routingSeed, func(router Router) (*Chain, ArrivalProcess, error) {
+		clock := NewSimClock(time.Now())
+		rng := rand.New(rand.NewSource(seed))
+		arrivals, err := newArrivalProcess(cfg.Arrivals, time.Duration(cfg.ArrivalRate)/time.Duration(cfg.ChainSize),
+			cfg.ArrivalTrace, rng)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		chain := &Chain{Router: router, Clock: clock, ChooseService: chooseServices(cfg.ServiceMix, rng)}
+		if cfg.Patience > 0 {
+			patience := rand.New(rand.NewSource(seed + 1))
+			chain.ChoosePatience = func() time.Duration {
+				return time.Duration(patience.ExpFloat64() * float64(cfg.Patience))
+			}
+		}
+		for ii := 0; ii < cfg.ChainSize; ii++ {
+			shop := &BarberShop{
+				ShopCapacity:    cfg.Capacity,
+				HairCurDuration: time.Duration(cfg.CutDuration),
+				BarberDoneChan:  make(chan bool),
+				GracePeriod:     time.Duration(cfg.GracePeriod),
+				Clock:           clock,
+				Menu:            cfg.menu(),
+				AgingThreshold:  time.Duration(cfg.AgingThreshold),
+			}
+			for _, barber := range cfg.barbers() {
+				shop.addBarber(barber)
+			}
+			chain.Shops = append(chain.Shops, shop)
+		}
+		return chain, arrivals, nil
+	})
+	if err != nil {
+		color.Red("*** Error comparing routing strategies: %v", err)
+		return
+	}
+	printComparison(reports)
 }
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Report is the end-of-day summary of a barbershop. It is built once the shop has closed and every
+// barber has gone home, so none of its numbers change afterwards.
+type Report struct {
+	ClientsServed     int
+	ClientsTurnedAway int
+	ClientsAfterHours int
+	ClientsReneged    int
+	AverageWait       time.Duration
+	MedianWait        time.Duration
+	P95Wait           time.Duration
+	WaitsByClass      map[ClientClass]WaitStats // the waits of the clients served, for each class of client
+	DayLength         time.Duration             // from the first barber starting work until the last one went home
+	Throughput        float64                   // clients served per hour of DayLength
+	BarberMinutes     float64                   // the time all the barbers spent at work, added up
+	BarbersHired      int
+	BarbersSentHome   int
+	Barbers           []BarberReport
+	Appointments      AppointmentStats
+	Revenue           Revenue
+}
+
+// WaitStats summarizes how long a group of clients waited before being served.
+type WaitStats struct {
+	Clients int
+	Average time.Duration
+	Median  time.Duration
+	P95     time.Duration
+}
+
+// BarberReport describes how a single barber spent the day.
+type BarberReport struct {
+	Name        string
+	Haircuts    int
+	OnDuty      time.Duration // from coming in to going home, not counting breaks
+	Busy        time.Duration
+	Napping     time.Duration
+	Breaks      time.Duration
+	Utilization float64 // the fraction of OnDuty spent cutting hair
+	Panics      int     // how many times the barber panicked, and had to be sent back to work
+}
+
+// report builds the end-of-day summary for the shop. It should only be called after Run has returned.
+func (shop *BarberShop) report() Report {
+	shop.mutex.Lock()
+	r := Report{
+		ClientsTurnedAway: shop.ClientsTurnedAway,
+		ClientsAfterHours: shop.ClientsAfterHours,
+		ClientsReneged:    shop.ClientsReneged,
+		BarbersHired:      shop.BarbersHired,
+		BarbersSentHome:   shop.BarbersSentHome,
+		Appointments:      shop.appointmentStats(),
+		Revenue:           shop.ledger.revenue(),
+	}
+	shop.mutex.Unlock()
+
+	shop.statsMutex.Lock()
+	defer shop.statsMutex.Unlock()
+
+	r.ClientsServed = len(shop.served)
+
+	// wait times, for everyone and for each class of client
+	overall := waitStats(shop.served)
+	r.AverageWait, r.MedianWait, r.P95Wait = overall.Average, overall.Median, overall.P95
+
+	byClass := make(map[ClientClass][]*Client)
+	for _, client := range shop.served {
+		byClass[client.Class] = append(byClass[client.Class], client)
+	}
+	r.WaitsByClass = make(map[ClientClass]WaitStats)
+	for class, clients := range byClass {
+		r.WaitsByClass[class] = waitStats(clients)
+	}
+
+	var opened, closed time.Time
+	for _, barber := range shop.barbers {
+		br := BarberReport{
+			Name:     barber.Name,
+			Haircuts: barber.cuts,
+			OnDuty:   barber.wentHome.Sub(barber.started) - barber.breaks,
+			Busy:     barber.busy,
+			Napping:  barber.napping,
+			Breaks:   barber.breaks,
+			Panics:   barber.panics,
+		}
+		if br.OnDuty > 0 {
+			br.Utilization = float64(br.Busy) / float64(br.OnDuty)
+		}
+		r.Barbers = append(r.Barbers, br)
+		r.BarberMinutes += br.OnDuty.Minutes()
+
+		if opened.IsZero() || barber.started.Before(opened) {
+			opened = barber.started
+		}
+		if barber.wentHome.After(closed) {
+			closed = barber.wentHome
+		}
+	}
+
+	r.DayLength = closed.Sub(opened)
+	if r.DayLength > 0 {
+		r.Throughput = float64(r.ClientsServed) / r.DayLength.Hours()
+	}
+
+	return r
+}
+
+// waitStats summarizes the wait times of the given clients.
+func waitStats(clients []*Client) WaitStats {
+	// wait times, sorted so that we can pick out the percentiles
+	waits := make([]time.Duration, 0, len(clients))
+	var totalWait time.Duration
+	for _, client := range clients {
+		waits = append(waits, client.WaitTime())
+		totalWait += client.WaitTime()
+	}
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+
+	stats := WaitStats{
+		Clients: len(waits),
+		Median:  percentile(waits, 50),
+		P95:     percentile(waits, 95),
+	}
+	if len(waits) > 0 {
+		stats.Average = totalWait / time.Duration(len(waits))
+	}
+	return stats
+}
+
+// percentile returns the p-th percentile of an already sorted slice, using the nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Text returns the report in a human-readable form.
+func (r Report) Text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Clients served:       %d\n", r.ClientsServed)
+	fmt.Fprintf(&b, "Clients turned away:  %d\n", r.ClientsTurnedAway)
+	fmt.Fprintf(&b, "Clients after hours:  %d\n", r.ClientsAfterHours)
+	fmt.Fprintf(&b, "Clients who gave up:  %d\n", r.ClientsReneged)
+	fmt.Fprintf(&b, "Wait (avg/p50/p95):   %v / %v / %v\n",
+		r.AverageWait.Round(time.Millisecond), r.MedianWait.Round(time.Millisecond), r.P95Wait.Round(time.Millisecond))
+	for _, class := range r.classes() {
+		w := r.WaitsByClass[class]
+		fmt.Fprintf(&b, "  %-19s %v / %v / %v (%d clients)\n", string(class)+":",
+			w.Average.Round(time.Millisecond), w.Median.Round(time.Millisecond), w.P95.Round(time.Millisecond), w.Clients)
+	}
+	fmt.Fprintf(&b, "Day length:           %v\n", r.DayLength.Round(time.Millisecond))
+	fmt.Fprintf(&b, "Throughput:           %.1f clients/hour\n", r.Throughput)
+	fmt.Fprintf(&b, "Barber-minutes:       %.1f (%d called in, %d sent home early)\n",
+		r.BarberMinutes, r.BarbersHired, r.BarbersSentHome)
+	for _, barber := range r.Barbers {
+		fmt.Fprintf(&b, "%s: %d haircuts, busy %v, napping %v, on break %v, %.0f%% utilized",
+			barber.Name, barber.Haircuts, barber.Busy.Round(time.Millisecond), barber.Napping.Round(time.Millisecond),
+			barber.Breaks.Round(time.Millisecond), barber.Utilization*100)
+		if barber.Panics > 0 {
+			fmt.Fprintf(&b, ", panicked %d times", barber.Panics)
+		}
+		b.WriteString("\n")
+	}
+	if a := r.Appointments; a.Booked > 0 {
+		fmt.Fprintf(&b, "Appointments:         %d booked, %d kept, %d missed\n", a.Booked, a.Kept, a.Missed)
+		fmt.Fprintf(&b, "  punctuality:        %d on time, %v late on average, started %v after the slot on average\n",
+			a.OnTime, a.AverageLateness.Round(time.Millisecond), a.AverageDelay.Round(time.Millisecond))
+		fmt.Fprintf(&b, "  walk-in wait:       %v caused by appointments\n", a.WalkInDelay.Round(time.Millisecond))
+	}
+	if m := r.Revenue; m.Total() > 0 || m.LostTurnedAway > 0 || m.LostReneged > 0 {
+		fmt.Fprintf(&b, "Revenue:              %v (%v for services, %v in tips)\n", m.Total(), m.Revenue, m.Tips)
+		for _, barber := range r.Barbers {
+			if e, ok := m.ByBarber[barber.Name]; ok {
+				fmt.Fprintf(&b, "  %-19s %v from %d clients\n", barber.Name+":", e.Total(), e.Clients)
+			}
+		}
+		for _, service := range m.services() {
+			e := m.ByService[service]
+			fmt.Fprintf(&b, "  %-19s %v from %d clients\n", string(service)+":", e.Total(), e.Clients)
+		}
+		for _, hour := range m.ByHour {
+			fmt.Fprintf(&b, "  %-19s %v from %d clients\n", hour.Hour.Format("15:04")+":", hour.Total(), hour.Clients)
+		}
+		fmt.Fprintf(&b, "Lost revenue:         %v turned away, %v gave up waiting\n", m.LostTurnedAway, m.LostReneged)
+	}
+
+	return b.String()
+}
+
+// classes returns the classes of client in WaitsByClass, in alphabetical order.
+func (r Report) classes() []ClientClass {
+	classes := make([]ClientClass, 0, len(r.WaitsByClass))
+	for class := range r.WaitsByClass {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i] < classes[j] })
+	return classes
+}
+
+// services returns the services in ByService, in alphabetical order.
+func (m Revenue) services() []Service {
+	services := make([]Service, 0, len(m.ByService))
+	for service := range m.ByService {
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i] < services[j] })
+	return services
+}
+
+// print writes the report to the console, in the same colors as the rest of the shop's log.
+func (r Report) print() {
+	color.Green("---------------------------------------------------------------------")
+	for _, line := range strings.Split(strings.TrimSpace(r.Text()), "\n") {
+		color.Yellow(line)
+	}
+}
+
+// jsonReport and jsonBarberReport are how a Report is written as JSON. Durations are written in seconds,
+// which are far easier to work with in other tools than Go's nanoseconds.
+type jsonReport struct {
+	ClientsServed     int                      `json:"clients_served"`
+	ClientsTurnedAway int                      `json:"clients_turned_away"`
+	ClientsAfterHours int                      `json:"clients_after_hours"`
+	ClientsReneged    int                      `json:"clients_reneged"`
+	AverageWait       float64                  `json:"average_wait_seconds"`
+	MedianWait        float64                  `json:"median_wait_seconds"`
+	P95Wait           float64                  `json:"p95_wait_seconds"`
+	WaitsByClass      map[string]jsonWaitStats `json:"waits_by_class"`
+	DayLength         float64                  `json:"day_length_seconds"`
+	Throughput        float64                  `json:"throughput_per_hour"`
+	BarberMinutes     float64                  `json:"barber_minutes"`
+	BarbersHired      int                      `json:"barbers_hired"`
+	BarbersSentHome   int                      `json:"barbers_sent_home_early"`
+	Barbers           []jsonBarberReport       `json:"barbers"`
+	Appointments      jsonAppointmentStats     `json:"appointments"`
+	Revenue           jsonRevenue              `json:"revenue"`
+}
+
+type jsonWaitStats struct {
+	Clients int     `json:"clients"`
+	Average float64 `json:"average_seconds"`
+	Median  float64 `json:"median_seconds"`
+	P95     float64 `json:"p95_seconds"`
+}
+
+type jsonEarnings struct {
+	Clients int   `json:"clients"`
+	Revenue Cents `json:"revenue"` // in dollars, like every amount of money
+	Tips    Cents `json:"tips"`
+	Total   Cents `json:"total"`
+}
+
+type jsonHourEarnings struct {
+	Hour time.Time `json:"hour"`
+	jsonEarnings
+}
+
+type jsonRevenue struct {
+	jsonEarnings
+	ByBarber       map[string]jsonEarnings `json:"by_barber"`
+	ByService      map[string]jsonEarnings `json:"by_service"`
+	ByHour         []jsonHourEarnings      `json:"by_hour"`
+	LostTurnedAway Cents                   `json:"lost_turned_away"`
+	LostReneged    Cents                   `json:"lost_reneged"`
+}
+
+func toJSONEarnings(e Earnings) jsonEarnings {
+	return jsonEarnings{Clients: e.Clients, Revenue: e.Revenue, Tips: e.Tips, Total: e.Total()}
+}
+
+type jsonAppointmentStats struct {
+	Booked          int     `json:"booked"`
+	Kept            int     `json:"kept"`
+	Missed          int     `json:"missed"`
+	OnTime          int     `json:"on_time"`
+	AverageLateness float64 `json:"average_lateness_seconds"`
+	AverageDelay    float64 `json:"average_delay_seconds"`
+	WalkInDelay     float64 `json:"walk_in_delay_seconds"`
+}
+
+type jsonBarberReport struct {
+	Name        string  `json:"name"`
+	Haircuts    int     `json:"haircuts"`
+	OnDuty      float64 `json:"on_duty_seconds"`
+	Busy        float64 `json:"busy_seconds"`
+	Napping     float64 `json:"napping_seconds"`
+	Breaks      float64 `json:"break_seconds"`
+	Utilization float64 `json:"utilization"`
+	Panics      int     `json:"panics"`
+}
+
+// MarshalJSON writes the report with its durations in seconds.
+func (r Report) MarshalJSON() ([]byte, error) {
+	jr := jsonReport{
+		ClientsServed:     r.ClientsServed,
+		ClientsTurnedAway: r.ClientsTurnedAway,
+		ClientsAfterHours: r.ClientsAfterHours,
+		ClientsReneged:    r.ClientsReneged,
+		AverageWait:       r.AverageWait.Seconds(),
+		MedianWait:        r.MedianWait.Seconds(),
+		P95Wait:           r.P95Wait.Seconds(),
+		DayLength:         r.DayLength.Seconds(),
+		Throughput:        r.Throughput,
+		BarberMinutes:     r.BarberMinutes,
+		BarbersHired:      r.BarbersHired,
+		BarbersSentHome:   r.BarbersSentHome,
+		WaitsByClass:      map[string]jsonWaitStats{},
+		Barbers:           []jsonBarberReport{},
+		Appointments: jsonAppointmentStats{
+			Booked:          r.Appointments.Booked,
+			Kept:            r.Appointments.Kept,
+			Missed:          r.Appointments.Missed,
+			OnTime:          r.Appointments.OnTime,
+			AverageLateness: r.Appointments.AverageLateness.Seconds(),
+			AverageDelay:    r.Appointments.AverageDelay.Seconds(),
+			WalkInDelay:     r.Appointments.WalkInDelay.Seconds(),
+		},
+		Revenue: jsonRevenue{
+			jsonEarnings:   toJSONEarnings(r.Revenue.Earnings),
+			ByBarber:       map[string]jsonEarnings{},
+			ByService:      map[string]jsonEarnings{},
+			ByHour:         []jsonHourEarnings{},
+			LostTurnedAway: r.Revenue.LostTurnedAway,
+			LostReneged:    r.Revenue.LostReneged,
+		},
+	}
+	for barber, e := range r.Revenue.ByBarber {
+		jr.Revenue.ByBarber[barber] = toJSONEarnings(e)
+	}
+	for service, e := range r.Revenue.ByService {
+		jr.Revenue.ByService[string(service)] = toJSONEarnings(e)
+	}
+	for _, hour := range r.Revenue.ByHour {
+		jr.Revenue.ByHour = append(jr.Revenue.ByHour, jsonHourEarnings{Hour: hour.Hour, jsonEarnings: toJSONEarnings(hour.Earnings)})
+	}
+	for class, w := range r.WaitsByClass {
+		jr.WaitsByClass[string(class)] = jsonWaitStats{
+			Clients: w.Clients,
+			Average: w.Average.Seconds(),
+			Median:  w.Median.Seconds(),
+			P95:     w.P95.Seconds(),
+		}
+	}
+	for _, barber := range r.Barbers {
+		jr.Barbers = append(jr.Barbers, jsonBarberReport{
+			Name:        barber.Name,
+			Haircuts:    barber.Haircuts,
+			OnDuty:      barber.OnDuty.Seconds(),
+			Busy:        barber.Busy.Seconds(),
+			Napping:     barber.Napping.Seconds(),
+			Breaks:      barber.Breaks.Seconds(),
+			Utilization: barber.Utilization,
+			Panics:      barber.Panics,
+		})
+	}
+
+	return json.Marshal(jr)
+}
+
+// JSON returns the report as indented JSON.
+func (r Report) JSON() (string, error) {
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
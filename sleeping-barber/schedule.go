@@ -0,0 +1,186 @@
+package main
+
+import (
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Schedule is when a barber works. All times are measured from the moment the shop opens.
+type Schedule struct {
+	Start  time.Duration // when the barber arrives
+	End    time.Duration // when the barber's shift is over; zero means the barber stays until closing time
+	Breaks []Break
+}
+
+// Break is time off during a barber's shift, such as lunch.
+type Break struct {
+	Start  time.Duration
+	Length time.Duration
+}
+
+// over reports whether the shift is over at time t.
+func (s Schedule) over(t time.Duration) bool {
+	return s.End > 0 && t >= s.End
+}
+
+// breakAt reports whether the barber is on a break at time t, and if so, when the break ends.
+func (s Schedule) breakAt(t time.Duration) (end time.Duration, onBreak bool) {
+	for _, b := range s.Breaks {
+		if t >= b.Start && t < b.Start+b.Length {
+			return b.Start + b.Length, true
+		}
+	}
+	return 0, false
+}
+
+// nextChange returns the next time after t at which the barber either goes on a break or reaches the end of
+// the shift, if there is one.
+func (s Schedule) nextChange(t time.Duration) (next time.Duration, ok bool) {
+	consider := func(at time.Duration) {
+		if at > t && (!ok || at < next) {
+			next, ok = at, true
+		}
+	}
+	for _, b := range s.Breaks {
+		consider(b.Start)
+	}
+	if s.End > 0 {
+		consider(s.End)
+	}
+	return next, ok
+}
+
+// sinceOpening returns how long the shop has been open. The caller must hold the shop's mutex.
+func (shop *BarberShop) sinceOpening() time.Duration {
+	return shop.Clock.Now().Sub(shop.opened)
+}
+
+// mayLeave reports whether a barber whose shift is over can go home: the last barbers cannot leave while
+// there are clients waiting that only they can serve, and nobody leaves a client with an appointment waiting. The
+// caller must hold the shop's mutex.
+func (shop *BarberShop) mayLeave(barber *barberStats) bool {
+	if shop.hasAppointmentWaiting(barber) {
+		return false
+	}
+	now := shop.sinceOpening()
+
+	shop.statsMutex.Lock()
+	defer shop.statsMutex.Unlock()
+
+	for seat := shop.room.line.Front(); seat != nil; seat = seat.Next() {
+		client := seat.Value.(*Client)
+		if !barber.canServe(client) {
+			continue
+		}
+
+		covered := false
+		for _, other := range shop.barbers {
+			if other == barber || other.home || other.dismissed || !other.canServe(client) {
+				continue
+			}
+			// a barber whose shift is over is leaving too, and one who has not come in by closing time never will
+			if !other.Schedule.over(now) && !(shop.doorsClosed() && other.started.IsZero()) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// restUntil takes the barber off the floor until the given time since opening, unless the barber is woken up
+// sooner, and returns how long the barber was away. The caller must hold the shop's mutex, which is released
+// while the barber rests and held again once the barber is back.
+func (shop *BarberShop) restUntil(barber *barberStats, until time.Duration, state BarberState) time.Duration {
+	started := shop.Clock.Now()
+	shop.setState(barber, state)
+	stop := shop.Clock.AfterFunc(until-shop.sinceOpening(), func() {
+		shop.mutex.Lock()
+		defer shop.mutex.Unlock()
+		shop.wakeUp(barber)
+	})
+
+	shop.resting = append(shop.resting, barber)
+	shop.Clock.park()
+	shop.mutex.Unlock()
+
+	<-barber.wake
+	stop()
+
+	shop.mutex.Lock()
+	shop.setState(barber, BarberAwake)
+	return shop.Clock.Now().Sub(started)
+}
+
+// wakeUp wakes the barber, whether the barber is napping or resting, and reports whether the barber was
+// asleep. The caller must hold the shop's mutex.
+func (shop *BarberShop) wakeUp(barber *barberStats) bool {
+	for _, sleepers := range []*[]*barberStats{&shop.napping, &shop.resting} {
+		for ii, sleeper := range *sleepers {
+			if sleeper == barber {
+				*sleepers = append((*sleepers)[:ii], (*sleepers)[ii+1:]...)
+				shop.Clock.unpark()
+				barber.wake <- true
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkSchedule keeps the barber to the barber's schedule: it waits for the shift to start and sits out any
+// break, and returns false once the barber should go home because the shift is over or because the shop has
+// closed. The caller must hold the shop's mutex, which may be released and held again in the meantime.
+func (shop *BarberShop) checkSchedule(barber *barberStats) bool {
+	for {
+		if barber.dismissed {
+			return false
+		}
+
+		now := shop.sinceOpening()
+		switch end, onBreak := barber.Schedule.breakAt(now); {
+		case now < barber.Schedule.Start && !shop.doorsClosed():
+			shop.restUntil(barber, barber.Schedule.Start, BarberOffDuty)
+			continue
+
+		case now < barber.Schedule.Start && shop.mayLeave(barber):
+			// a barber whose shift has not started by closing time does not come in at all, unless a client is
+			// waiting whom nobody else can serve, as may happen when the shop closes early
+			return false
+
+		case barber.Schedule.over(now):
+			// the last barbers cannot leave while there are clients waiting for them
+			if !shop.mayLeave(barber) {
+				return true
+			}
+			color.Cyan("%s's shift is over.", barber.Name)
+			return false
+
+		case onBreak:
+			// there is no need to come back from a break after closing time if nobody is waiting for the barber
+			if shop.doorsClosed() && !shop.room.waitingFor(barber.Barber) {
+				return false
+			}
+			color.Yellow("%s goes on a break.", barber.Name)
+			rested := shop.restUntil(barber, end, BarberOnBreak)
+			shop.statsMutex.Lock()
+			barber.breaks += rested
+			shop.statsMutex.Unlock()
+			color.Yellow("%s is back from the break.", barber.Name)
+			continue
+		}
+
+		if barber.started.IsZero() {
+			shop.statsMutex.Lock()
+			barber.started = shop.Clock.Now()
+			barber.state = BarberAwake
+			shop.statsMutex.Unlock()
+			color.Yellow("%s goes to the waiting room to check for clients.", barber.Name)
+		}
+		return true
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Service is something a client can ask a barber to do.
+type Service string
+
+const (
+	ServiceCut   Service = "cut"
+	ServiceShave Service = "shave"
+	ServiceColor Service = "color"
+)
+
+// Barber describes a barber: how fast the barber works, and what the barber knows how to do.
+type Barber struct {
+	Name     string
+	Speed    float64   // 2 means twice as fast as the menu says, 0.5 half as fast; zero is treated as 1
+	Services []Service // the services this barber performs; empty means all of them
+	Schedule Schedule  // when the barber works; the zero Schedule means from opening until closing time
+}
+
+// canServe reports whether the barber can do what the client asked for.
+func (b Barber) canServe(client *Client) bool {
+	if len(b.Services) == 0 {
+		return true
+	}
+	for _, service := range b.Services {
+		if service == client.Service {
+			return true
+		}
+	}
+	return false
+}
+
+// durationOf is how long this barber takes over a service that the menu says takes base.
+func (b Barber) durationOf(base time.Duration) time.Duration {
+	if b.Speed <= 0 {
+		return base
+	}
+	return time.Duration(float64(base) / b.Speed)
+}
+
+// ServiceWeight is how often clients ask for a service, relative to the other services in a mix.
+type ServiceWeight struct {
+	Service Service
+	Weight  float64
+}
+
+// chooseServices returns a function that picks what each new client asks for, at random according to mix.
+func chooseServices(mix []ServiceWeight, rng *rand.Rand) func() Service {
+	var total float64
+	for _, w := range mix {
+		total += w.Weight
+	}
+
+	return func() Service {
+		if len(mix) == 0 || total <= 0 {
+			return ServiceCut
+		}
+		pick := rng.Float64() * total
+		for _, w := range mix {
+			if pick < w.Weight {
+				return w.Service
+			}
+			pick -= w.Weight
+		}
+		return mix[len(mix)-1].Service
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ShopState is where the shop is in its day. A shop goes from closed, through opening, open and closing, back to
+// closed, just once.
+type ShopState int
+
+const (
+	ShopClosed  ShopState = iota // before the shop opens, and once everyone has gone home
+	ShopOpening                  // the shop is booking the day's appointments, and not taking clients yet
+	ShopOpen                     // the shop is taking clients
+	ShopClosing                  // the doors are closed, and the barbers are finishing with whoever is still waiting
+)
+
+var shopStateNames = map[ShopState]string{
+	ShopClosed:  "closed",
+	ShopOpening: "opening",
+	ShopOpen:    "open",
+	ShopClosing: "closing",
+}
+
+func (s ShopState) String() string {
+	if name, ok := shopStateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("ShopState(%d)", int(s))
+}
+
+func (s ShopState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *ShopState) UnmarshalText(text []byte) error {
+	for state, name := range shopStateNames {
+		if name == string(text) {
+			*s = state
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown shop state %q", text)
+}
+
+// shopTransitions are the changes of state a shop may go through. A shop that cannot book its appointments
+// closes again without ever opening.
+var shopTransitions = map[ShopState][]ShopState{
+	ShopClosed:  {ShopOpening},
+	ShopOpening: {ShopOpen, ShopClosed},
+	ShopOpen:    {ShopClosing},
+	ShopClosing: {ShopClosed},
+}
+
+// StateChange is the shop going from one state to another.
+type StateChange struct {
+	From ShopState
+	To   ShopState
+	Time time.Time // by the shop's clock
+}
+
+// stateObservers are the channels that everyone watching the shop's state is told of changes on. They are
+// protected by the shop's mutex.
+type stateObservers struct {
+	channels map[chan StateChange]bool
+	done     bool // the shop has closed for the day, so there will be no more changes
+}
+
+// setShopState moves the shop to a new state, and tells everyone who is watching. Moving to a state that cannot
+// follow the current one is an error, and leaves the state as it was. The caller must hold the shop's mutex.
+func (shop *BarberShop) setShopState(to ShopState) error {
+	from := shop.state
+	if shop.observers.done {
+		return fmt.Errorf("the shop has closed for the day, so it cannot be %s", to)
+	}
+	valid := false
+	for _, next := range shopTransitions[from] {
+		valid = valid || next == to
+	}
+	if !valid {
+		return fmt.Errorf("the shop cannot go from %s to %s", from, to)
+	}
+
+	shop.state = to
+	change := StateChange{From: from, To: to, Time: shop.Clock.Now()}
+	for ch := range shop.observers.channels {
+		select {
+		case ch <- change:
+		default:
+		}
+	}
+
+	// once the shop closes for the day, nothing more is going to happen to it
+	if to == ShopClosed && from != ShopClosed {
+		for ch := range shop.observers.channels {
+			close(ch)
+		}
+		shop.observers.channels = nil
+		shop.observers.done = true
+	}
+	return nil
+}
+
+// watchState returns a channel of every change of the shop's state from now on, and a function to call once no
+// more changes are wanted. The shop changes state no more than four times a day, and the channel has room for
+// all of them, so a watcher never misses a change, and never holds up the shop. The channel is closed once the
+// shop has closed for the day, or when unsubscribe is called.
+func (shop *BarberShop) watchState() (changes <-chan StateChange, unsubscribe func()) {
+	shop.mutex.Lock()
+	defer shop.mutex.Unlock()
+
+	ch := make(chan StateChange, 4)
+	if shop.observers.done {
+		close(ch)
+		return ch, func() {}
+	}
+	if shop.observers.channels == nil {
+		shop.observers.channels = make(map[chan StateChange]bool)
+	}
+	shop.observers.channels[ch] = true
+
+	return ch, func() {
+		shop.mutex.Lock()
+		defer shop.mutex.Unlock()
+		if shop.observers.channels[ch] {
+			delete(shop.observers.channels, ch)
+			close(ch)
+		}
+	}
+}
+
+// shopState returns where the shop is in its day.
+func (shop *BarberShop) shopState() ShopState {
+	shop.mutex.Lock()
+	defer shop.mutex.Unlock()
+	return shop.state
+}
+
+// doorsClosed reports whether the shop has closed its doors for the day, so barbers go home once they are done.
+// The caller must hold the shop's mutex.
+func (shop *BarberShop) doorsClosed() bool {
+	return shop.state == ShopClosing || shop.state == ShopClosed && shop.observers.done
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_shopStates(t *testing.T) {
+	shop := newTestShop(3, time.Second)
+	shop.addBarber(Barber{Name: "Frank"})
+	opens := shop.Clock.Now()
+
+	changes, _ := shop.watchState()
+	runTestDay(t, shop, 10*time.Second, &FixedArrivals{Interval: 500 * time.Millisecond})
+
+	// the shop goes through its day one state at a time, and the doors close right on time
+	var got []StateChange
+	for change := range changes {
+		got = append(got, change)
+	}
+	var states []ShopState
+	for _, change := range got {
+		states = append(states, change.To)
+	}
+	if want := []ShopState{ShopOpening, ShopOpen, ShopClosing, ShopClosed}; !reflect.DeepEqual(states, want) {
+		t.Fatalf("expected the shop to be %v in turn, but it was %v", want, states)
+	}
+	if got[0].From != ShopClosed || !got[1].Time.Equal(opens) || !got[2].Time.Equal(opens.Add(10*time.Second)) {
+		t.Errorf("unexpected changes %+v", got)
+	}
+
+	// a shop opens only once, and whoever comes to watch afterwards is told there is nothing more to see
+	if err := shop.Run(context.Background(), &FixedArrivals{Interval: time.Second}); err == nil || !strings.Contains(err.Error(), "closed for the day") {
+		t.Errorf("expected opening the shop again to be an error, but got %v", err)
+	}
+	if late, _ := shop.watchState(); !isClosed(late) {
+		t.Error("expected no more changes once the shop had closed for the day")
+	}
+}
+
+func Test_shopStateTransitions(t *testing.T) {
+	shop := newTestShop(3, time.Second)
+
+	// closing a shop that never opened is not allowed, and changes nothing
+	shop.mutex.Lock()
+	err := shop.setShopState(ShopClosing)
+	state := shop.state
+	shop.mutex.Unlock()
+	if err == nil || state != ShopClosed {
+		t.Errorf("expected closing a closed shop to fail, but got %v, and the shop is %s", err, state)
+	}
+
+	// a shop that cannot book its appointments closes again without ever opening
+	shop.addBarber(Barber{Name: "Frank"})
+	shop.Appointments = []Appointment{{Name: "Mrs. Jones", Barber: "Bob", At: time.Second}}
+	changes, _ := shop.watchState()
+	if err := shop.Run(context.Background(), &FixedArrivals{Interval: time.Second}); err == nil {
+		t.Fatal("expected an appointment with nobody to stop the shop opening")
+	}
+	var states []ShopState
+	for change := range changes {
+		states = append(states, change.To)
+	}
+	if want := []ShopState{ShopOpening, ShopClosed}; !reflect.DeepEqual(states, want) {
+		t.Errorf("expected the shop to be %v in turn, but it was %v", want, states)
+	}
+}
+
+// isClosed reports whether changes has been closed, without waiting for it to be.
+func isClosed(changes <-chan StateChange) bool {
+	select {
+	case _, ok := <-changes:
+		return !ok
+	default:
+		return false
+	}
+}
+
+func Test_closingStopsArrivals(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+
+	// on the wall clock, with the next client not due for an hour, the shop still closes on time, and nothing is
+	// left waiting for that client once it has
+	shop := newTestShop(3, time.Millisecond)
+	shop.Clock = RealClock{}
+	shop.addBarber(Barber{Name: "Frank"})
+	runTestDay(t, shop, 50*time.Millisecond, &TraceArrivals{Offsets: []time.Duration{time.Hour}})
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if now := runtime.NumGoroutine(); now > goroutines {
+		t.Errorf("expected %d goroutines once the shop had closed, but there are %d", goroutines, now)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SweepPoint is one combination of parameters in a sweep, along with the seed of one run of it.
+type SweepPoint struct {
+	Barbers     int
+	Capacity    int
+	ArrivalRate time.Duration // the average time between clients
+	CutDuration time.Duration
+	Seed        int64
+}
+
+// SweepResult is how one run of a sweep went.
+type SweepResult struct {
+	SweepPoint
+	Report Report
+}
+
+// sweepPoints returns every combination of the sweep's parameters, each once for every seed. Parameters the sweep
+// leaves out are taken from cfg, and barbers go from one up to cfg.MaxBarbers. The seeds are the same for every
+// combination, so that the combinations are compared over the same days, as far as they can be.
+func (cfg Config) sweepPoints(seed int64) []SweepPoint {
+	sweep := cfg.Sweep
+	barbers := sweep.Barbers
+	if len(barbers) == 0 {
+		for n := 1; n <= cfg.MaxBarbers; n++ {
+			barbers = append(barbers, n)
+		}
+	}
+	capacities := sweep.Capacities
+	if len(capacities) == 0 {
+		capacities = []int{cfg.Capacity}
+	}
+	arrivalRates := sweep.ArrivalRates
+	if len(arrivalRates) == 0 {
+		arrivalRates = []Duration{cfg.ArrivalRate}
+	}
+	cutDurations := sweep.CutDurations
+	if len(cutDurations) == 0 {
+		cutDurations = []Duration{cfg.CutDuration}
+	}
+
+	var points []SweepPoint
+	for _, n := range barbers {
+		for _, capacity := range capacities {
+			for _, rate := range arrivalRates {
+				for _, cut := range cutDurations {
+					for run := 0; run < sweep.Seeds; run++ {
+						points = append(points, SweepPoint{Barbers: n, Capacity: capacity, ArrivalRate: time.Duration(rate),
+							CutDuration: time.Duration(cut), Seed: seed + int64(run)})
+					}
+				}
+			}
+		}
+	}
+	return points
+}
+
+// runSweep runs a day at the shop for every point, on workers goroutines at once, and returns the results in the
+// same order as the points. If any of the days could not be run, it returns the first such error.
+func runSweep(points []SweepPoint, workers int, runDay func(SweepPoint) (Report, error)) ([]SweepResult, error) {
+	results := make([]SweepResult, len(points))
+	errs := make([]error, len(points))
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for ii := 0; ii < workers; ii++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range next {
+				report, err := runDay(points[index])
+				results[index], errs[index] = SweepResult{SweepPoint: points[index], Report: report}, err
+			}
+		}()
+	}
+	for index := range points {
+		next <- index
+	}
+	close(next)
+	wg.Wait()
+
+	for ii, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%d barbers, %d seats, a client every %v, %v cuts, seed %d: %w", points[ii].Barbers,
+				points[ii].Capacity, points[ii].ArrivalRate, points[ii].CutDuration, points[ii].Seed, err)
+		}
+	}
+	return results, nil
+}
+
+// sweepDay runs the day cfg describes at the shop, changed as point says, on a simulated clock so that it takes no
+// time at all. The shop's barbers are the first of cfg's barbers, and as many ordinary barbers besides as point
+// calls for; appointments with barbers who are not there are left out, and nobody is called in or sent home early.
+func (cfg Config) sweepDay(point SweepPoint) (Report, error) {
+	rng := rand.New(rand.NewSource(point.Seed))
+	arrivals, err := newArrivalProcess(cfg.Arrivals, point.ArrivalRate, cfg.ArrivalTrace, rand.New(rand.NewSource(rng.Int63())))
+	if err != nil {
+		return Report{}, err
+	}
+
+	cfg.CutDuration = Duration(point.CutDuration)
+	barbers := cfg.barbers()
+	if len(barbers) > point.Barbers {
+		barbers = barbers[:point.Barbers]
+	}
+	for len(barbers) < point.Barbers {
+		barbers = append(barbers, Barber{Name: fmt.Sprintf("Barber #%d", len(barbers)+1), Speed: 1})
+	}
+	working := make(map[string]bool)
+	for _, barber := range barbers {
+		working[barber.Name] = true
+	}
+	var appointments []Appointment
+	for _, a := range cfg.appointments() {
+		if working[a.Barber] {
+			appointments = append(appointments, a)
+		}
+	}
+
+	clock := NewSimClock(time.Date(2023, time.January, 2, 9, 0, 0, 0, time.UTC))
+	shop := &BarberShop{
+		ShopCapacity:     point.Capacity,
+		HairCurDuration:  point.CutDuration,
+		BarberDoneChan:   make(chan bool),
+		GracePeriod:      time.Duration(cfg.GracePeriod),
+		Clock:            clock,
+		Menu:             cfg.menu(),
+		ChooseService:    chooseServices(cfg.ServiceMix, rand.New(rand.NewSource(rng.Int63()))),
+		AgingThreshold:   time.Duration(cfg.AgingThreshold),
+		Appointments:     appointments,
+		AppointmentGrace: time.Duration(cfg.AppointmentGrace),
+		Prices:           cfg.prices(),
+	}
+	vips := rand.New(rand.NewSource(rng.Int63()))
+	shop.ChooseClass = func() ClientClass {
+		if vips.Float64() < cfg.VIPShare {
+			return ClassVIP
+		}
+		return ClassRegular
+	}
+	if cfg.Patience > 0 {
+		patience := rand.New(rand.NewSource(rng.Int63()))
+		shop.ChoosePatience = func() time.Duration {
+			return time.Duration(patience.ExpFloat64() * float64(cfg.Patience))
+		}
+	}
+	for _, barber := range barbers {
+		shop.addBarber(barber)
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(time.Duration(cfg.OpenFor)))
+	defer cancel()
+	if err := shop.Run(ctx, arrivals); err != nil {
+		return Report{}, err
+	}
+	return shop.report(), nil
+}
+
+// sweepColumns are the columns of a sweep's CSV. Times are in seconds, so that spreadsheets can do sums with them.
+var sweepColumns = []string{
+	"barbers", "capacity", "arrival_rate", "cut_duration", "seed",
+	"served", "turned_away", "after_hours", "reneged",
+	"average_wait", "median_wait", "p95_wait", "throughput_per_hour", "utilization",
+}
+
+// writeSweepCSV writes a header, and then a row for each of the results.
+func writeSweepCSV(w io.Writer, results []SweepResult) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(sweepColumns); err != nil {
+		return err
+	}
+
+	seconds := func(d time.Duration) string { return strconv.FormatFloat(d.Seconds(), 'f', 3, 64) }
+	for _, r := range results {
+		// how busy the barbers were, all together
+		var onDuty, busy time.Duration
+		for _, barber := range r.Report.Barbers {
+			onDuty += barber.OnDuty
+			busy += barber.Busy
+		}
+		utilization := 0.0
+		if onDuty > 0 {
+			utilization = float64(busy) / float64(onDuty)
+		}
+
+		row := []string{
+			strconv.Itoa(r.Barbers), strconv.Itoa(r.Capacity), seconds(r.ArrivalRate), seconds(r.CutDuration),
+			strconv.FormatInt(r.Seed, 10),
+			strconv.Itoa(r.Report.ClientsServed), strconv.Itoa(r.Report.ClientsTurnedAway),
+			strconv.Itoa(r.Report.ClientsAfterHours), strconv.Itoa(r.Report.ClientsReneged),
+			seconds(r.Report.AverageWait), seconds(r.Report.MedianWait), seconds(r.Report.P95Wait),
+			strconv.FormatFloat(r.Report.Throughput, 'f', 1, 64), strconv.FormatFloat(utilization, 'f', 3, 64),
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func sweepTestConfig() Config {
+	cfg := defaultConfig()
+	cfg.Barbers = []BarberConfig{{Name: "Frank", Speed: 1}}
+	cfg.Appointments = []AppointmentConfig{{Name: "Mrs. Jones", Barber: "Frank", At: Duration(time.Second)}}
+	cfg.MaxBarbers = 3
+	cfg.OpenFor = Duration(5 * time.Second)
+	cfg.Sweep = SweepConfig{Output: "-", Capacities: []int{2, 4}, Seeds: 2}
+	return cfg
+}
+
+func Test_sweepPoints(t *testing.T) {
+	points := sweepTestConfig().sweepPoints(10)
+
+	// barbers from one up to the most there may be, for each capacity, for each seed
+	if len(points) != 12 {
+		t.Fatalf("expected 3 numbers of barbers, 2 capacities and 2 seeds to make 12 points, but got %d", len(points))
+	}
+	first, last := points[0], points[len(points)-1]
+	if first.Barbers != 1 || first.Capacity != 2 || first.Seed != 10 || first.CutDuration != cutDuration {
+		t.Errorf("unexpected first point %+v", first)
+	}
+	if last.Barbers != 3 || last.Capacity != 4 || last.Seed != 11 {
+		t.Errorf("unexpected last point %+v", last)
+	}
+}
+
+func Test_runSweep(t *testing.T) {
+	cfg := sweepTestConfig()
+	points := cfg.sweepPoints(1)
+
+	// running the days side by side makes no difference to who turns up; which barber gets which client may differ
+	// when several are free at once, so not everything else is the same every time
+	parallel, err := runSweep(points, 4, cfg.sweepDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sequential, err := runSweep(points, 1, cfg.sweepDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ii := range points {
+		if parallel[ii].SweepPoint != points[ii] {
+			t.Fatalf("expected result %d to be for %+v, but it is for %+v", ii, points[ii], parallel[ii].SweepPoint)
+		}
+		arrived := func(r Report) int {
+			return r.ClientsServed + r.ClientsTurnedAway + r.ClientsReneged + r.ClientsAfterHours
+		}
+		if got, want := arrived(parallel[ii].Report), arrived(sequential[ii].Report); got != want || got == 0 {
+			t.Errorf("expected the same clients at %+v however many days run at once, but got %d and %d",
+				points[ii], got, want)
+		}
+	}
+
+	// more barbers serve more clients
+	if fewer, more := parallel[0].Report.ClientsServed, parallel[8].Report.ClientsServed; more <= fewer {
+		t.Errorf("expected 3 barbers to serve more than 1, but they served %d and %d", more, fewer)
+	}
+
+	var out bytes.Buffer
+	if err := writeSweepCSV(&out, parallel); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(points)+1 || !reflect.DeepEqual(rows[0], sweepColumns) {
+		t.Fatalf("expected a header and %d rows, but got %d rows starting with %v", len(points), len(rows), rows[0])
+	}
+	if row := rows[1]; row[0] != "1" || row[1] != "2" || row[2] != "0.100" || row[3] != "1.000" || row[4] != "1" {
+		t.Errorf("unexpected first row %v", row)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"container/list"
+	"time"
+)
+
+// waitingRoom holds the clients waiting for a haircut, in the order they sat down. Unlike a channel, it
+// lets a barber skip past clients who want something the barber cannot do or who have to wait for a VIP,
+// and it lets a client who has run out of patience get up and leave from anywhere in the line. It is not
+// safe for concurrent use on its own: the shop's mutex guards it, so that seating a client and waking a
+// barber happen together.
+type waitingRoom struct {
+	line     list.List                 // of *Client, longest waiting first
+	seats    map[*Client]*list.Element // where each client is in line, so that anyone can leave at once
+	standing map[*Client]bool          // clients who were put back in line without taking a seat
+	peak     int                       // the most seats that have been taken at once
+}
+
+// len returns the number of clients waiting, whether they have a seat or not.
+func (room *waitingRoom) len() int {
+	return room.line.Len()
+}
+
+// taken returns the number of seats taken, which is what the shop's capacity limits.
+func (room *waitingRoom) taken() int {
+	return room.line.Len() - len(room.standing)
+}
+
+// add seats a client at the back of the line.
+func (room *waitingRoom) add(client *Client) {
+	if room.seats == nil {
+		room.seats = make(map[*Client]*list.Element)
+	}
+	room.seats[client] = room.line.PushBack(client)
+	room.seated()
+}
+
+// putBack puts a client who was already being served back at the front of the line, ahead of everyone else. The
+// client gave up a seat when the barber called them, and a walk-in may have taken it since, so the client waits
+// standing, rather than taking a seat from anyone or being turned away.
+func (room *waitingRoom) putBack(client *Client) {
+	if room.seats == nil {
+		room.seats = make(map[*Client]*list.Element)
+	}
+	if room.standing == nil {
+		room.standing = make(map[*Client]bool)
+	}
+	room.seats[client] = room.line.PushFront(client)
+	room.standing[client] = true
+	room.seated()
+}
+
+// seated keeps track of how full the room has been.
+func (room *waitingRoom) seated() {
+	if room.taken() > room.peak {
+		room.peak = room.taken()
+	}
+}
+
+// remove takes a client out of the line, wherever the client is, and reports whether the client was there.
+func (room *waitingRoom) remove(client *Client) bool {
+	seat, ok := room.seats[client]
+	if !ok {
+		return false
+	}
+	room.line.Remove(seat)
+	delete(room.seats, client)
+	delete(room.standing, client)
+	return true
+}
+
+// waitingFor reports whether anyone the barber can serve is waiting.
+func (room *waitingRoom) waitingFor(barber Barber) bool {
+	for seat := room.line.Front(); seat != nil; seat = seat.Next() {
+		if barber.canServe(seat.Value.(*Client)) {
+			return true
+		}
+	}
+	return false
+}
+
+// takeFor removes and returns the client the barber should serve next, or nil if the barber cannot serve
+// anyone who is waiting. VIP clients go first, in the order they sat down, but so that regular clients are not
+// kept waiting forever, a regular client who has waited at least aging counts as a VIP. An aging of zero means
+// regular clients are never promoted.
+func (room *waitingRoom) takeFor(barber Barber, now time.Time, aging time.Duration) *Client {
+	var first *Client
+	for seat := room.line.Front(); seat != nil; seat = seat.Next() {
+		client := seat.Value.(*Client)
+		if !barber.canServe(client) {
+			continue
+		}
+
+		if client.Class == ClassVIP || (aging > 0 && now.Sub(client.Seated) >= aging) {
+			room.remove(client)
+			return client
+		}
+		if first == nil {
+			first = client
+		}
+	}
+
+	if first != nil {
+		room.remove(first)
+	}
+	return first
+}
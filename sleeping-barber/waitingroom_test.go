@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_waitingRoom_takeFor(t *testing.T) {
+	opened := time.Date(2023, time.January, 2, 9, 0, 0, 0, time.UTC)
+
+	var theTests = []struct {
+		name     string
+		aging    time.Duration
+		expected string
+	}{
+		{"no aging", 0, "VIP"},
+		{"regular client has not waited long enough", 5 * time.Second, "VIP"},
+		{"regular client is promoted", 2 * time.Second, "Regular"},
+	}
+
+	for _, e := range theTests {
+		var room waitingRoom
+		room.add(&Client{Name: "Regular", Class: ClassRegular, Seated: opened})
+		room.add(&Client{Name: "VIP", Class: ClassVIP, Seated: opened.Add(time.Second)})
+
+		client := room.takeFor(Barber{Name: "Frank"}, opened.Add(2*time.Second), e.aging)
+		if client == nil || client.Name != e.expected {
+			t.Errorf("%s: expected %s to be served first but got %v", e.name, e.expected, client)
+		}
+		if room.len() != 1 {
+			t.Errorf("%s: expected one client to be left waiting but there are %d", e.name, room.len())
+		}
+	}
+}
+
+func Test_waitingRoom_putBack(t *testing.T) {
+	var room waitingRoom
+	sitting, returned := newClient(1), newClient(2)
+	room.add(sitting)
+	room.putBack(returned)
+
+	// the client who was put back is first in line, but has no seat
+	if room.len() != 2 || room.taken() != 1 || room.peak != 1 {
+		t.Errorf("expected 2 clients waiting in 1 seat, but %d are waiting in %d (at most %d)", room.len(), room.taken(), room.peak)
+	}
+	if next := room.takeFor(Barber{Name: "Frank"}, time.Now(), 0); next != returned {
+		t.Errorf("expected %s to be next, but got %s", returned, next)
+	}
+	if room.taken() != 1 || len(room.standing) != 0 {
+		t.Errorf("expected 1 seat to be taken and nobody to be standing, but %d and %d are", room.taken(), len(room.standing))
+	}
+}